@@ -0,0 +1,133 @@
+package cronkit
+
+import (
+	"time"
+
+	"github.com/textthree/cvgokit/gokit"
+)
+
+// 重试等待时间的上限
+const maxRetryBackoff = time.Hour
+
+// 默认每个任务保留的执行记录数
+const defaultHistoryLimit = 20
+
+// 任务的一次执行记录，重试的每一次尝试单独记录
+type Execution struct {
+	EntryID EntryID
+	// 第几次尝试，从 1 开始，大于 1 表示重试
+	Attempt  int
+	Start    time.Time
+	Duration time.Duration
+	// 任务返回的错误，panic 时为 *gokit.PanicError
+	Err error
+}
+
+// 任务每次尝试开始执行前调用
+type JobStartHook func(id EntryID, attempt int)
+
+// 任务每次尝试执行完毕后调用，exec 与写入执行记录的内容一致
+type JobDoneHook func(exec Execution)
+
+// 设置任务开始执行时的钩子，可用于打点或者链路追踪
+func WithOnJobStart(hook JobStartHook) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onJobStart = hook
+	}
+}
+
+// 设置任务执行完毕时的钩子，可用于记录日志、上报耗时和错误
+func WithOnJobDone(hook JobDoneHook) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onJobDone = hook
+	}
+}
+
+// 设置每个任务在内存中保留的最近执行记录数，默认 20，小于等于 0 表示不保留
+func WithHistoryLimit(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.historyLimit = n
+	}
+}
+
+// 任务返回错误或 panic 时重试，最多重试 max 次
+// 第 n 次重试前等待 backoff * 2^(n-1)，最长 1 小时；等待期间调度器停止、任务暂停或移除时放弃重试
+func WithRetry(max int, backoff time.Duration) JobOption {
+	return func(e *entry) {
+		e.retries = max
+		e.backoff = backoff
+	}
+}
+
+// 任务最近的执行记录，按时间先后排列
+func (s *Scheduler) History(id EntryID) ([]Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.find(id)
+	if e == nil {
+		return nil, ErrEntryNotFound
+	}
+	return append([]Execution(nil), e.history...), nil
+}
+
+// 执行一次任务，包括失败后的重试；stop 为调度器本次运行的停止信号
+func (s *Scheduler) execute(e *entry, stop <-chan struct{}) {
+	for attempt := 1; ; attempt++ {
+		if s.onJobStart != nil {
+			s.onJobStart(e.id, attempt)
+		}
+		start := time.Now()
+		err := gokit.CallWithRecover(e.fn)
+		exec := Execution{
+			EntryID:  e.id,
+			Attempt:  attempt,
+			Start:    start.In(s.loc),
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		s.record(e, exec)
+		if s.onJobDone != nil {
+			s.onJobDone(exec)
+		}
+		if err == nil || attempt > e.retries || !s.canRetry(e) {
+			return
+		}
+		timer := time.NewTimer(retryBackoff(e.backoff, attempt))
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+		if !s.canRetry(e) {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) record(e *entry, exec Execution) {
+	if s.historyLimit <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(e.history) >= s.historyLimit {
+		e.history = append(e.history[:0], e.history[len(e.history)-s.historyLimit+1:]...)
+	}
+	e.history = append(e.history, exec)
+}
+
+func (s *Scheduler) canRetry(e *entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running && !e.paused && !e.removed
+}
+
+// 第 attempt 次执行失败后的等待时间
+func retryBackoff(backoff time.Duration, attempt int) time.Duration {
+	d := backoff
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
@@ -1,21 +1,25 @@
-package fn
+package cronkit
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-// 每天执行一次的计划任务
+// 每天执行一次的计划任务，会一直阻塞当前协程
+// 按 timekit.TimeZone 的墙上时间计算，当天的时间还没到时当天就会执行，
+// 夏令时切换的日子也只执行一次，规则见 SpecSchedule；需要停止或管理任务时使用 Scheduler
 func CronJobDaily(fn func(), hour, min, sec int) {
+	schedule, err := Parse(fmt.Sprintf("%d %d %d * * *", sec, min, hour))
+	if err != nil {
+		panic(err)
+	}
+	loc := defaultLocation()
 	for {
-		// 获取当前时间，给 next 用
-		now := time.Now()
-		// 通过 now 偏移 24 小时
-		next := now.Add(time.Hour * 24) //time.Hour * 24
-		// 然后获取下一个执行时间
-		next = time.Date(next.Year(), next.Month(), next.Day(), hour, min, sec, 0, next.Location())
-		// 再计算当前时间到下一个执行时间的间隔，设置一个定时器
-		t := time.NewTimer(next.Sub(time.Now()))
+		// 计算下一个执行时间，设置一个定时器
+		next := schedule.Next(time.Now().In(loc))
+		t := time.NewTimer(time.Until(next))
 		<-t.C
 		// 执行定时任务
 		fn()
 	}
 }
-
@@ -0,0 +1,201 @@
+package cronkit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 字段的取值范围及可用的名称
+type fieldBounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = fieldBounds{name: "second", min: 0, max: 59}
+	minuteBounds = fieldBounds{name: "minute", min: 0, max: 59}
+	hourBounds   = fieldBounds{name: "hour", min: 0, max: 23}
+	domBounds    = fieldBounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = fieldBounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// 0 和 7 都表示星期天
+	dowBounds = fieldBounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// 解析 cron 表达式
+// 支持 5 个字段（分 时 日 月 周）或 6 个字段（秒 分 时 日 月 周），
+// 每个字段支持 *、?（仅日和周）、数值、范围 1-5、步长 */15 或 10-50/10 或 5/10、列表 1,3,5，
+// 月和周支持英文缩写 JAN-DEC、SUN-SAT，不区分大小写；
+// 另外支持 @yearly、@annually、@monthly、@weekly、@daily、@midnight、@hourly 和 @every 1h30m
+// 表达式前可以加 CRON_TZ=时区 或 TZ=时区 指定按哪个时区计算，不指定时使用调用 Next 时传入时间的时区，
+// 夏令时的处理见 SpecSchedule
+// e.g:
+//
+//	cronkit.Parse("0 9 * * MON-FRI")  // 工作日 9 点
+//	cronkit.Parse("*/10 * * * * *")   // 每 10 秒
+//	cronkit.Parse("@every 5m")
+//	cronkit.Parse("CRON_TZ=America/New_York 0 30 2 * * *")
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	// 去掉时区前缀后的表达式，错误信息中仍使用原始的 spec
+	expr := spec
+	var loc *time.Location
+	if name, ok := cutTimeZone(expr); ok {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("cronkit: parse %q: %w", spec, err)
+		}
+		_, rest, _ := strings.Cut(expr, " ")
+		expr = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("cronkit: parse %q: %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cronkit: parse %q: interval must be at least 1s", spec)
+		}
+		return Every(d), nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		macro, ok := macros[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("cronkit: parse %q: unknown macro", spec)
+		}
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cronkit: parse %q: expected 5 or 6 fields, got %d", spec, len(fields))
+	}
+	s := &SpecSchedule{loc: loc}
+	var err error
+	parsers := []struct {
+		bits  *uint64
+		star  *bool
+		bound fieldBounds
+	}{
+		{&s.second, nil, secondBounds},
+		{&s.minute, nil, minuteBounds},
+		{&s.hour, nil, hourBounds},
+		{&s.dom, &s.domStar, domBounds},
+		{&s.month, nil, monthBounds},
+		{&s.dow, &s.dowStar, dowBounds},
+	}
+	for i, p := range parsers {
+		var star bool
+		if *p.bits, star, err = parseField(fields[i], p.bound, p.star != nil); err != nil {
+			return nil, fmt.Errorf("cronkit: parse %q: %w", spec, err)
+		}
+		if p.star != nil {
+			*p.star = star
+		}
+	}
+	// 7 也是星期天
+	if hasBit(s.dow, 7) {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return s, nil
+}
+
+// 取出 CRON_TZ=xxx 或 TZ=xxx 前缀中的时区名
+func cutTimeZone(spec string) (string, bool) {
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec, prefix) {
+			name, _, _ := strings.Cut(spec[len(prefix):], " ")
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// 解析单个字段，star 表示字段为不带步长的 * 或 ?
+func parseField(field string, b fieldBounds, allowQuestion bool) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		partBits, partStar, err := parsePart(part, b, allowQuestion)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= partBits
+		star = star || partStar
+	}
+	return bits, star, nil
+}
+
+// 解析 * ? n a-b 及其 /step 形式
+func parsePart(part string, b fieldBounds, allowQuestion bool) (bits uint64, star bool, err error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+	var start, end int
+	switch rangeExpr {
+	case "*", "?":
+		if rangeExpr == "?" && !allowQuestion {
+			return 0, false, fmt.Errorf("%s: ? is only allowed in day of month and day of week", b.name)
+		}
+		start, end, star = b.min, b.max, true
+	default:
+		lo, hi, isRange := strings.Cut(rangeExpr, "-")
+		if start, err = parseValue(lo, b); err != nil {
+			return 0, false, err
+		}
+		end = start
+		if isRange {
+			if end, err = parseValue(hi, b); err != nil {
+				return 0, false, err
+			}
+		} else if hasStep {
+			// 5/10 表示从 5 开始到最大值，每隔 10
+			end = b.max
+		}
+	}
+	step := 1
+	if hasStep {
+		if step, err = strconv.Atoi(stepExpr); err != nil || step <= 0 {
+			return 0, false, fmt.Errorf("%s: invalid step %q", b.name, stepExpr)
+		}
+		star = star && step == 1
+	}
+	if start > end {
+		return 0, false, fmt.Errorf("%s: range start %d is beyond end %d", b.name, start, end)
+	}
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, star, nil
+}
+
+func parseValue(s string, b fieldBounds) (int, error) {
+	n, ok := b.names[strings.ToUpper(s)]
+	if !ok {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil {
+			return 0, fmt.Errorf("%s: invalid value %q", b.name, s)
+		}
+	}
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", b.name, n, b.min, b.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,82 @@
+package cronkit
+
+import "time"
+
+// 上一次执行还没结束又到了执行时间时的处理策略
+type OverlapPolicy int
+
+const (
+	// 允许同时执行，默认策略
+	OverlapAllow OverlapPolicy = iota
+	// 跳过本次执行
+	OverlapSkip
+	// 排队，等上一次执行结束后立即执行；调度器停止、任务暂停或移除时清空排队
+	OverlapQueue
+)
+
+// 错过执行时间时的处理策略
+// 进程休眠、时钟跳变或者调度器 Stop 之后再 Start 都可能错过执行时间，
+// 实际执行时间比计划晚 misfireThreshold 以上即视为错过
+type MisfirePolicy int
+
+const (
+	// 错过的执行合并为一次，立即补执行，默认策略
+	MisfireFireOnce MisfirePolicy = iota
+	// 错过几次就补执行几次，最多补执行 maxMisfireRuns 次，补执行同样受 OverlapPolicy 约束
+	MisfireFireAll
+	// 不补执行，等下一个执行时间
+	MisfireIgnore
+)
+
+const misfireThreshold = time.Second
+
+// 一次最多补执行的次数，防止长时间停止后 MisfireFireAll 瞬间开出大量协程
+const maxMisfireRuns = 100
+
+// 添加任务时的选项
+type JobOption func(*entry)
+
+// 设置任务的 OverlapPolicy
+func WithOverlap(policy OverlapPolicy) JobOption {
+	return func(e *entry) {
+		e.overlap = policy
+	}
+}
+
+// 设置任务的 MisfirePolicy
+func WithMisfire(policy MisfirePolicy) JobOption {
+	return func(e *entry) {
+		e.misfire = policy
+	}
+}
+
+// 计算到 now 为止到期的执行次数，并把 next 推进到 now 之后
+// 返回值已按 MisfirePolicy 处理，last 为最后一个到期的计划时间
+func (e *entry) due(now time.Time) (runs int, last time.Time) {
+	due := 0
+	for !e.next.IsZero() && !e.next.After(now) {
+		if due == maxMisfireRuns {
+			// 错过的次数太多时不再逐个推进，直接从当前时间计算下一次
+			e.next = e.schedule.Next(now)
+			break
+		}
+		due++
+		last = e.next
+		e.next = e.schedule.Next(e.next)
+	}
+	if due == 0 {
+		return 0, last
+	}
+	onTime := now.Sub(last) <= misfireThreshold
+	switch e.misfire {
+	case MisfireFireAll:
+		return due, last
+	case MisfireIgnore:
+		if onTime {
+			return 1, last
+		}
+		return 0, last
+	default:
+		return 1, last
+	}
+}
@@ -0,0 +1,121 @@
+package cronkit
+
+import "time"
+
+// 计划，根据给定时间计算下一次执行时间
+type Schedule interface {
+	// 返回严格晚于 t 的下一次执行时间，不会再执行时返回零值
+	Next(t time.Time) time.Time
+}
+
+// 由 cron 表达式解析出来的计划，每个字段用位图表示允许的取值
+// 按墙上时间匹配，夏令时切换时的行为：
+//   - 拨快时跳过的时间（如 02:30 不存在）在切换时刻执行一次，不会被跳过
+//   - 拨慢时重复的时间（如 01:30 出现两次）只在第一次出现时执行；
+//     小时字段为 * 的任务例外，重复的那一小时按真实时间照常执行
+type SpecSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// 日和星期字段是否为 * 或 ?，两者都有限制时任意一个匹配即可，与标准 cron 一致
+	domStar, dowStar bool
+	// CRON_TZ 指定的时区，nil 表示使用传入时间的时区
+	loc *time.Location
+}
+
+const (
+	// 最多向后查找的年数，超过时认为不会再执行，例如 2 月 30 日
+	maxSearchYears = 5
+	everyHour      = 1<<24 - 1
+)
+
+func (s *SpecSchedule) Next(t time.Time) time.Time {
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+	loc := t.Location()
+	from := t.Truncate(time.Second).Add(time.Second)
+	yearLimit := from.Year() + maxSearchYears
+	// 按 UTC 偏移不变的时段逐段查找，每段内墙上时间与真实时间一一对应
+	for {
+		start, end := from.ZoneBounds()
+		_, offset := from.Zone()
+		w := wallClock(from)
+		// 拨慢后重复的墙上时间在上一段已经出现过，只有小时字段为 * 的任务才再执行一次
+		if !start.IsZero() && s.hour != everyHour {
+			_, prevOffset := start.Add(-time.Second).Zone()
+			if repeatedUntil := wallOf(start, prevOffset); w.Before(repeatedUntil) {
+				w = repeatedUntil
+			}
+		}
+		if w = s.nextWall(w, yearLimit); w.IsZero() {
+			return time.Time{}
+		}
+		next := time.Unix(w.Unix()-int64(offset), 0).In(loc)
+		if end.IsZero() || next.Before(end) {
+			return next
+		}
+		// 拨快时跳过的墙上时间在切换时刻执行
+		if _, nextOffset := end.Zone(); nextOffset > offset && w.Before(wallOf(end, nextOffset)) {
+			return end.In(loc)
+		}
+		from = end
+	}
+}
+
+// 从 w 开始（含）查找下一个匹配的墙上时间，w 用 UTC 表示不带时区的墙上时间
+func (s *SpecSchedule) nextWall(w time.Time, yearLimit int) time.Time {
+	for w.Year() <= yearLimit {
+		switch {
+		case !hasBit(s.month, int(w.Month())):
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !hasBit(s.hour, w.Hour()):
+			w = w.Truncate(time.Hour).Add(time.Hour)
+		case !hasBit(s.minute, w.Minute()):
+			w = w.Truncate(time.Minute).Add(time.Minute)
+		case !hasBit(s.second, w.Second()):
+			w = w.Add(time.Second)
+		default:
+			return w
+		}
+	}
+	return time.Time{}
+}
+
+func (s *SpecSchedule) dayMatches(w time.Time) bool {
+	domMatch := hasBit(s.dom, w.Day())
+	dowMatch := hasBit(s.dow, int(w.Weekday()))
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// 固定间隔执行的计划，对应 @every
+type ConstantDelaySchedule struct {
+	Delay time.Duration
+}
+
+// 每隔 d 执行一次，d 按秒向下取整，不足 1 秒按 1 秒处理
+func Every(d time.Duration) ConstantDelaySchedule {
+	d = max(d.Truncate(time.Second), time.Second)
+	return ConstantDelaySchedule{Delay: d}
+}
+
+func (s ConstantDelaySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(time.Second).Add(s.Delay)
+}
+
+// t 的墙上时间，用 UTC 表示，精确到秒
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// 时刻 t 按 UTC 偏移 offset 秒计算的墙上时间
+func wallOf(t time.Time, offset int) time.Time {
+	return time.Unix(t.Unix()+int64(offset), 0).UTC()
+}
+
+func hasBit(bits uint64, n int) bool {
+	return bits&(1<<uint(n)) != 0
+}
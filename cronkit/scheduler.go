@@ -0,0 +1,393 @@
+package cronkit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/textthree/cvgokit/timekit"
+)
+
+// 任务 ID，添加任务时返回，用于移除、暂停和恢复任务
+type EntryID int
+
+// 任务 ID 不存在或者任务已被移除
+var ErrEntryNotFound = errors.New("cronkit: entry not found")
+
+// 计划任务调度器
+// 所有任务共用一个调度协程，按各自的计划计算下一次执行时间，到点后开新协程执行
+// e.g:
+//
+//	s := cronkit.NewScheduler()
+//	id, _ := s.AddFunc("0 */5 * * * *", func() { fmt.Println("every 5 minutes") })
+//	s.AddFunc("@daily", cleanup)
+//	s.Start()
+//	...
+//	s.Pause(id)
+//	s.Stop(ctx)
+type Scheduler struct {
+	// 计算执行时间使用的时区，表达式中用 CRON_TZ 指定了时区的任务不受影响
+	loc     *time.Location
+	mu      sync.Mutex
+	entries []*entry
+	nextID  EntryID
+	running bool
+	// 新增、恢复任务后唤醒调度协程重新计算等待时间
+	wake chan struct{}
+	// Stop 时关闭，通知调度协程退出
+	stop chan struct{}
+	// 调度协程退出后关闭
+	done chan struct{}
+	// 正在执行的任务
+	jobs sync.WaitGroup
+
+	historyLimit int
+	onJobStart   JobStartHook
+	onJobDone    JobDoneHook
+}
+
+type entry struct {
+	id       EntryID
+	spec     string
+	schedule Schedule
+	fn       func() error
+	overlap  OverlapPolicy
+	misfire  MisfirePolicy
+	paused   bool
+	removed  bool
+	// 正在执行的次数和 OverlapQueue 下排队等待的次数
+	running, queued int
+	// 下一次执行时间，零值表示不会再执行
+	next time.Time
+	// 上一次执行时间
+	prev time.Time
+	// 失败后的最大重试次数和首次重试的等待时间
+	retries int
+	backoff time.Duration
+	// 最近的执行记录
+	history []Execution
+}
+
+// 任务的快照，由 Entries 返回
+type Entry struct {
+	ID EntryID
+	// 添加任务时的 cron 表达式，通过 Schedule 添加时为空
+	Spec     string
+	Schedule Schedule
+	Paused   bool
+	// 正在执行的次数
+	Running int
+	// 下一次执行时间，调度器未启动、任务已暂停或不会再执行时为零值；
+	// 调度器停止后保留停止时的值，再次 Start 时据此按 MisfirePolicy 处理停止期间错过的执行
+	Next time.Time
+	// 上一次执行时间，还没执行过时为零值
+	Prev time.Time
+}
+
+type SchedulerOption func(*Scheduler)
+
+// 设置调度器的时区，默认为 timekit.TimeZone
+func WithLocation(loc *time.Location) SchedulerOption {
+	return func(s *Scheduler) {
+		s.loc = loc
+	}
+}
+
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{loc: defaultLocation(), wake: make(chan struct{}, 1), historyLimit: defaultHistoryLimit}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// timekit.TimeZone 对应的时区，加载失败时使用 time.Local
+func defaultLocation() *time.Location {
+	loc, err := time.LoadLocation(timekit.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// 调度器时区的当前时间
+func (s *Scheduler) now() time.Time {
+	return time.Now().In(s.loc)
+}
+
+// 按 cron 表达式添加任务，表达式的语法见 Parse
+// e.g:
+//
+//	s.AddFunc("@every 1m", sync, cronkit.WithOverlap(cronkit.OverlapSkip), cronkit.WithMisfire(cronkit.MisfireIgnore))
+func (s *Scheduler) AddFunc(spec string, fn func(), opts ...JobOption) (EntryID, error) {
+	return s.AddJob(spec, wrapFunc(fn), opts...)
+}
+
+// 添加返回错误的任务，错误会写入执行记录并交给 OnJobDone 钩子，配合 WithRetry 可以失败重试
+// e.g:
+//
+//	s.AddJob("0 3 * * *", backup, cronkit.WithRetry(3, time.Minute), cronkit.WithOverlap(cronkit.OverlapSkip))
+func (s *Scheduler) AddJob(spec string, fn func() error, opts ...JobOption) (EntryID, error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	return s.add(spec, schedule, fn, opts), nil
+}
+
+// 按自定义的计划添加任务
+func (s *Scheduler) Schedule(schedule Schedule, fn func(), opts ...JobOption) EntryID {
+	return s.add("", schedule, wrapFunc(fn), opts)
+}
+
+// 按自定义的计划添加返回错误的任务
+func (s *Scheduler) ScheduleJob(schedule Schedule, fn func() error, opts ...JobOption) EntryID {
+	return s.add("", schedule, fn, opts)
+}
+
+func wrapFunc(fn func()) func() error {
+	return func() error {
+		fn()
+		return nil
+	}
+}
+
+func (s *Scheduler) add(spec string, schedule Schedule, fn func() error, opts []JobOption) EntryID {
+	s.mu.Lock()
+	s.nextID++
+	e := &entry{id: s.nextID, spec: spec, schedule: schedule, fn: fn}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if s.running {
+		e.next = schedule.Next(s.now())
+	}
+	s.entries = append(s.entries, e)
+	s.mu.Unlock()
+	s.notify()
+	return e.id
+}
+
+// 移除任务，已经在执行的不受影响
+func (s *Scheduler) Remove(id EntryID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.id == id {
+			e.removed = true
+			e.queued = 0
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return nil
+		}
+	}
+	return ErrEntryNotFound
+}
+
+// 暂停任务，暂停期间到点的执行直接跳过
+func (s *Scheduler) Pause(id EntryID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.find(id)
+	if e == nil {
+		return ErrEntryNotFound
+	}
+	e.paused = true
+	e.queued = 0
+	e.next = time.Time{}
+	return nil
+}
+
+// 恢复暂停的任务，从当前时间开始重新计算下一次执行时间
+func (s *Scheduler) Resume(id EntryID) error {
+	s.mu.Lock()
+	e := s.find(id)
+	if e == nil {
+		s.mu.Unlock()
+		return ErrEntryNotFound
+	}
+	if e.paused {
+		e.paused = false
+		if s.running {
+			e.next = e.schedule.Next(s.now())
+		}
+	}
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// 所有任务的快照，按添加顺序排列
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e.snapshot())
+	}
+	return entries
+}
+
+// 获取单个任务的快照
+func (s *Scheduler) Entry(id EntryID) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.find(id)
+	if e == nil {
+		return Entry{}, ErrEntryNotFound
+	}
+	return e.snapshot(), nil
+}
+
+// 开始调度，不会阻塞，重复调用无效；Stop 之后可以再次 Start
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	now := s.now()
+	for _, e := range s.entries {
+		// 之前运行过的任务保留停止时的 next，由调度协程按 MisfirePolicy 补执行
+		if !e.paused && e.next.IsZero() {
+			e.next = e.schedule.Next(now)
+		}
+	}
+	go s.run(s.stop, s.done)
+}
+
+// 停止调度并等待正在执行的任务结束，等待重试的任务会放弃重试
+// ctx 到期时不再等待，返回 ctx.Err()，此时未结束的任务仍会在后台继续执行
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.running = false
+		for _, e := range s.entries {
+			e.queued = 0
+		}
+		close(s.stop)
+		done := s.done
+		s.mu.Unlock()
+		<-done
+	} else {
+		s.mu.Unlock()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var next time.Time
+		for _, e := range s.entries {
+			if runs, last := e.due(now); runs > 0 {
+				e.prev = last
+				for range runs {
+					s.dispatch(e)
+				}
+			}
+			if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+				next = e.next
+			}
+		}
+		s.mu.Unlock()
+
+		// 没有待执行的任务时一直等到有新任务加入
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			timeout = timer.C
+		}
+		select {
+		case <-timeout:
+		case <-s.wake:
+		case <-stop:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// 按 OverlapPolicy 执行一次任务，调用方需持有锁
+func (s *Scheduler) dispatch(e *entry) {
+	if e.running > 0 {
+		switch e.overlap {
+		case OverlapSkip:
+			return
+		case OverlapQueue:
+			e.queued++
+			return
+		}
+	}
+	s.startJob(e)
+}
+
+// 开协程执行任务，调用方需持有锁
+// 任务的 panic 会被恢复并作为 *gokit.PanicError 记录，不会导致进程退出
+func (s *Scheduler) startJob(e *entry) {
+	e.running++
+	s.jobs.Add(1)
+	stop := s.stop
+	go func() {
+		defer s.jobs.Done()
+		s.execute(e, stop)
+		s.mu.Lock()
+		e.running--
+		if e.queued > 0 && s.running && !e.paused && !e.removed {
+			e.queued--
+			s.startJob(e)
+		}
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Scheduler) find(id EntryID) *entry {
+	for _, e := range s.entries {
+		if e.id == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (e *entry) snapshot() Entry {
+	return Entry{
+		ID:       e.id,
+		Spec:     e.spec,
+		Schedule: e.schedule,
+		Paused:   e.paused,
+		Running:  e.running,
+		Next:     e.next,
+		Prev:     e.prev,
+	}
+}
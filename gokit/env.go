@@ -2,28 +2,304 @@ package gokit
 
 import (
 	"bufio"
+	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // 获取模块名称
+// 从当前目录开始逐级向上查找 go.mod，只解析 go.mod 本身，不读取工作区；需要工作区等更多信息时使用 LoadGoMod
 func GetModuleName() (string, error) {
-	file, err := os.Open("go.mod")
+	file, err := FindGoMod(".")
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("获取模块名称出错: %w", err)
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "module") {
-			// 提取 module 名称
-			return strings.TrimSpace(strings.TrimPrefix(line, "module")), nil
+	mod, err := ParseGoMod(file)
+	if err != nil {
+		return "", fmt.Errorf("获取模块名称出错: %w", err)
+	}
+	return mod.Module, nil
+}
+
+// 某个配置字段加载失败的原因
+type EnvFieldError struct {
+	// 结构体字段路径，如 DB.Port
+	Field string
+	// 环境变量名，如 DB_PORT
+	Key string
+	Err error
+}
+
+func (e *EnvFieldError) Error() string {
+	return fmt.Sprintf("env %s (field %s): %v", e.Key, e.Field, e.Err)
+}
+
+func (e *EnvFieldError) Unwrap() error {
+	return e.Err
+}
+
+// 必填的环境变量没有设置
+var ErrEnvRequired = errors.New("required but not set")
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 从环境变量填充配置结构体，cfg 必须是结构体指针
+// dotenvFiles 为可选的 .env 文件，会先通过 LoadDotEnv 加载，不存在的文件直接跳过
+// 支持的标签：
+//   - env:"PORT" 环境变量名，env:"PORT,required" 表示必填，env:"-" 忽略该字段
+//   - default:"8080" 环境变量没有设置或为空时使用的默认值
+//   - required:"true" 同 env 中的 required
+//   - sep:";" 切片元素的分隔符，默认为逗号
+//   - envPrefix:"DB_" 用于嵌套结构体，内部字段的环境变量名都加上该前缀
+//
+// 支持 string、bool、整数、浮点数、time.Duration、实现了 encoding.TextUnmarshaler 的类型，以及它们的切片和指针
+// 所有字段的错误会合并后一起返回，每个错误都是 *EnvFieldError
+// e.g:
+//
+//	type Config struct {
+//		Port    int           `env:"PORT" default:"8080"`
+//		Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//		Hosts   []string      `env:"HOSTS,required"`
+//		DB      struct {
+//			DSN string `env:"DSN,required"`
+//		} `envPrefix:"DB_"`
+//	}
+//	var cfg Config
+//	err := gokit.LoadEnv(&cfg, ".env")
+func LoadEnv(cfg any, dotenvFiles ...string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gokit: LoadEnv expects a pointer to struct, got %T", cfg)
+	}
+	if err := LoadDotEnv(dotenvFiles...); err != nil {
+		return err
+	}
+	var errs []error
+	loadEnvStruct(v.Elem(), "", "", &errs)
+	return errors.Join(errs...)
+}
+
+// 加载 .env 文件到环境变量，已经存在的环境变量不会被覆盖，不存在的文件直接跳过
+// 支持 # 注释、export 前缀、单双引号，双引号中支持 \n 等转义
+func LoadDotEnv(files ...string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		vars, err := parseDotEnv(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for key, value := range vars {
+			if _, ok := os.LookupEnv(key); ok {
+				continue
+			}
+			if err := os.Setenv(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseDotEnv(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+			// 引号之后只允许跟 # 注释
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNo)
+			}
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected %q after quoted value", lineNo, rest)
+			}
+			if value[0] == '\'' {
+				value = value[1:end]
+				break
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = unquoted
+		default:
+			// 没有引号时 " #" 之后是注释
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+// 找出与 value[0] 配对的结束引号的位置，双引号中跳过 \ 转义的字符，找不到时返回 -1
+func closingQuote(value string) int {
+	quote := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && quote == '"':
+			i++
+		case value[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// 递归填充结构体字段，path 为字段路径，prefix 为环境变量名前缀
+func loadEnvStruct(v reflect.Value, path, prefix string, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldValue := v.Field(i)
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+
+		tag, hasTag := field.Tag.Lookup("env")
+		if tag == "-" {
+			continue
+		}
+		// 没有 env 标签的结构体字段视为嵌套配置
+		if !hasTag && isNestedEnvStruct(field.Type) {
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					fieldValue.Set(reflect.New(field.Type.Elem()))
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			loadEnvStruct(fieldValue, fieldPath, prefix+field.Tag.Get("envPrefix"), errs)
+			continue
+		}
+		if !hasTag {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		key := prefix + name
+		required := options == "required" || field.Tag.Get("required") == "true"
+		value := os.Getenv(key)
+		if value == "" {
+			value = field.Tag.Get("default")
+		}
+		if value == "" {
+			if required {
+				*errs = append(*errs, &EnvFieldError{Field: fieldPath, Key: key, Err: ErrEnvRequired})
+			}
+			continue
+		}
+		sep := field.Tag.Get("sep")
+		if sep == "" {
+			sep = ","
+		}
+		if err := setEnvValue(fieldValue, value, sep); err != nil {
+			*errs = append(*errs, &EnvFieldError{Field: fieldPath, Key: key, Err: err})
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return "", err
+}
+
+func isNestedEnvStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// 把字符串转换为字段的类型并赋值
+func setEnvValue(v reflect.Value, value, sep string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setEnvValue(elem.Elem(), value, sep); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(value, sep)
+		slice := reflect.MakeSlice(v.Type(), 0, len(parts))
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setEnvValue(elem, part, sep); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
 	}
-	return "", fmt.Errorf("获取模块名称出错，当前目录找不到 go.mod 文件")
+	return nil
 }
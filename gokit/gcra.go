@@ -0,0 +1,90 @@
+package gokit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GCRA(通用信元速率算法)限流器
+// 只记录一个"理论到达时间"，限流效果与令牌桶相同，没有浮点累计误差，也便于放到 Redis 等共享存储中实现
+type GCRA struct {
+	mu sync.Mutex
+	// 每个请求占用的时间，即 1/rate
+	interval time.Duration
+	// 允许的突发请求数
+	burst int
+	// 理论到达时间，请求按 interval 匀速到达时下一个请求应该到达的时间
+	tat time.Time
+}
+
+// rate 每秒放行的请求数，burst 允许的突发请求数
+func NewGCRA(rate float64, burst int) *GCRA {
+	return &GCRA{
+		interval: time.Duration(float64(time.Second) / rate),
+		burst:    burst,
+	}
+}
+
+func (this *GCRA) TakeToken(await ...bool) bool {
+	return takeToken(this, await)
+}
+
+func (this *GCRA) TakeN(n int) bool {
+	ok, _ := this.take(time.Now(), n)
+	return ok
+}
+
+func (this *GCRA) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+func (this *GCRA) WaitN(ctx context.Context, n int) error {
+	if n > this.burst {
+		return errExceedLimit(n, this.burst)
+	}
+	return waitUntil(ctx, func(now time.Time) (bool, time.Duration) {
+		return this.take(now, n)
+	})
+}
+
+func (this *GCRA) Close() {}
+
+// 当前的配额状态
+func (this *GCRA) Status() (limit, remaining int, retryAfter time.Duration) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	tat := this.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	// 理论到达时间距离上限还能容纳多少个间隔
+	headroom := now.Add(time.Duration(this.burst) * this.interval).Sub(tat)
+	remaining = int(headroom / this.interval)
+	if remaining < 1 {
+		retryAfter = this.interval - headroom
+	}
+	return this.burst, remaining, retryAfter
+}
+
+// 尝试放行 n 个请求，失败时返回需要等待的时间
+func (this *GCRA) take(now time.Time, n int) (bool, time.Duration) {
+	if n <= 0 {
+		return true, 0
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	tat := this.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(time.Duration(n) * this.interval)
+	// 新的理论到达时间最多只能领先当前时间 burst 个间隔
+	allowAt := newTat.Add(-time.Duration(this.burst) * this.interval)
+	if now.Before(allowAt) {
+		return false, allowAt.Sub(now)
+	}
+	this.tat = newTat
+	return true, 0
+}
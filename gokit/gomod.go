@@ -0,0 +1,351 @@
+package gokit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 解析后的 go.mod
+type GoMod struct {
+	// go.mod 文件的绝对路径
+	File string
+	// 模块根目录，即 go.mod 所在目录
+	Dir string
+	// 模块名称
+	Module string
+	// go 指令声明的版本，如 1.22
+	Go string
+	// toolchain 指令，如 go1.22.1，没有时为空
+	Toolchain string
+	Require   []ModRequire
+	Replace   []ModReplace
+	Exclude   []ModVersion
+	// 模块所属的工作区，不在任何 go.work 中时为 nil
+	Workspace *GoWork
+}
+
+// 模块路径和版本
+type ModVersion struct {
+	Path    string
+	Version string
+}
+
+// require 指令
+type ModRequire struct {
+	Path    string
+	Version string
+	// 是否带有 // indirect 注释
+	Indirect bool
+}
+
+// replace 指令，New.Version 为空时 New.Path 是本地目录
+type ModReplace struct {
+	Old ModVersion
+	New ModVersion
+}
+
+// 解析后的 go.work
+type GoWork struct {
+	// go.work 文件的绝对路径
+	File      string
+	Go        string
+	Toolchain string
+	// use 指令中的模块目录，已转换为绝对路径
+	Use     []string
+	Replace []ModReplace
+}
+
+// 从 dir 开始逐级向上查找 go.mod，返回其绝对路径
+func FindGoMod(dir string) (string, error) {
+	return findUpward(dir, "go.mod")
+}
+
+// 从 dir 开始查找 go.mod 并解析，同时找出模块所属的工作区
+// 工作区的查找规则与 go 命令一致：GOWORK=off 时不使用工作区，GOWORK 为文件路径时使用该文件，
+// 为空或 auto 时从模块目录逐级向上查找 go.work
+func LoadGoMod(dir string) (*GoMod, error) {
+	file, err := FindGoMod(dir)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := ParseGoMod(file)
+	if err != nil {
+		return nil, err
+	}
+	workFile := os.Getenv("GOWORK")
+	switch workFile {
+	case "off":
+		return mod, nil
+	case "", "auto":
+		if workFile, err = findUpward(mod.Dir, "go.work"); err != nil {
+			return mod, nil
+		}
+	}
+	work, err := ParseGoWork(workFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range work.Use {
+		if use == mod.Dir {
+			mod.Workspace = work
+			break
+		}
+	}
+	return mod, nil
+}
+
+// 解析 go.mod 文件
+func ParseGoMod(file string) (*GoMod, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	mod := &GoMod{File: file, Dir: filepath.Dir(file)}
+	err = parseModFile(file, func(verb string, args []string, comment string) error {
+		switch verb {
+		case "module":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: module module/path")
+			}
+			mod.Module = args[0]
+		case "go":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: go 1.23")
+			}
+			mod.Go = args[0]
+		case "toolchain":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: toolchain go1.23.0")
+			}
+			mod.Toolchain = args[0]
+		case "require":
+			if len(args) != 2 {
+				return fmt.Errorf("usage: require module/path v1.2.3")
+			}
+			mod.Require = append(mod.Require, ModRequire{
+				Path:     args[0],
+				Version:  args[1],
+				Indirect: isIndirect(comment),
+			})
+		case "exclude":
+			if len(args) != 2 {
+				return fmt.Errorf("usage: exclude module/path v1.2.3")
+			}
+			mod.Exclude = append(mod.Exclude, ModVersion{Path: args[0], Version: args[1]})
+		case "replace":
+			replace, err := parseReplace(args, mod.Dir)
+			if err != nil {
+				return err
+			}
+			mod.Replace = append(mod.Replace, replace)
+		}
+		// retract、godebug、tool 等其余指令不关心
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mod.Module == "" {
+		return nil, fmt.Errorf("%s: no module directive", file)
+	}
+	return mod, nil
+}
+
+// 解析 go.work 文件
+func ParseGoWork(file string) (*GoWork, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	work := &GoWork{File: file}
+	dir := filepath.Dir(file)
+	err = parseModFile(file, func(verb string, args []string, comment string) error {
+		switch verb {
+		case "go":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: go 1.23")
+			}
+			work.Go = args[0]
+		case "toolchain":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: toolchain go1.23.0")
+			}
+			work.Toolchain = args[0]
+		case "use":
+			if len(args) != 1 {
+				return fmt.Errorf("usage: use local/dir")
+			}
+			work.Use = append(work.Use, absPath(dir, args[0]))
+		case "replace":
+			replace, err := parseReplace(args, dir)
+			if err != nil {
+				return err
+			}
+			work.Replace = append(work.Replace, replace)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// replace old [v] => new [v]，new 为本地目录时转换为绝对路径
+func parseReplace(args []string, dir string) (ModReplace, error) {
+	arrow := -1
+	for i, arg := range args {
+		if arg == "=>" {
+			arrow = i
+			break
+		}
+	}
+	if arrow < 1 || arrow > 2 || len(args)-arrow-1 < 1 || len(args)-arrow-1 > 2 {
+		return ModReplace{}, fmt.Errorf("usage: replace module/path [v1.2.3] => other/module v1.4.5 | local/dir")
+	}
+	var replace ModReplace
+	replace.Old.Path = args[0]
+	if arrow == 2 {
+		replace.Old.Version = args[1]
+	}
+	replace.New.Path = args[arrow+1]
+	if len(args) == arrow+3 {
+		replace.New.Version = args[arrow+2]
+	} else if isLocalPath(replace.New.Path) {
+		replace.New.Path = absPath(dir, replace.New.Path)
+	}
+	return replace, nil
+}
+
+func isLocalPath(path string) bool {
+	return path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, `.\`) || strings.HasPrefix(path, `..\`) || filepath.IsAbs(path)
+}
+
+func absPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// 从 dir 开始逐级向上查找文件
+func findUpward(dir, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		file := filepath.Join(dir, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s not found in %s or any parent directory", name, dir)
+		}
+		dir = parent
+	}
+}
+
+// 逐行解析 go.mod / go.work 的通用语法，每条指令回调一次
+// 块语法 require ( ... ) 中的每一行都会以块的指令名回调；comment 为行尾 // 之后的注释
+func parseModFile(file string, handle func(verb string, args []string, comment string) error) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	block := ""
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		tokens, comment, err := tokenizeModLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		var verb string
+		var args []string
+		switch {
+		case block != "" && len(tokens) == 1 && tokens[0] == ")":
+			block = ""
+			continue
+		case block != "":
+			verb, args = block, tokens
+		case len(tokens) == 2 && tokens[1] == "(":
+			block = tokens[0]
+			continue
+		default:
+			verb, args = tokens[0], tokens[1:]
+		}
+		if err := handle(verb, args, comment); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if block != "" {
+		return fmt.Errorf("%s: unterminated %s block", file, block)
+	}
+	return nil
+}
+
+// 把一行拆成单词，支持 "..." 和 `...` 引起来的路径，返回去掉 // 注释后的单词和注释内容
+func tokenizeModLine(line string) (tokens []string, comment string, err error) {
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(line[i:], "//"):
+			return tokens, line[i+2:], nil
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, "", fmt.Errorf("unterminated quoted string")
+			}
+			s, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, "", err
+			}
+			tokens = append(tokens, s)
+			i = end + 1
+		case c == '`':
+			end := strings.IndexByte(line[i+1:], '`')
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated raw string")
+			}
+			tokens = append(tokens, line[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t\r()\"`", rune(line[end])) && !strings.HasPrefix(line[end:], "//") {
+				end++
+			}
+			tokens = append(tokens, line[i:end])
+			i = end
+		}
+	}
+	return tokens, "", nil
+}
+
+// 与 modfile 的规则一致：注释去掉空白后是 indirect，或者以 indirect; 开头，如 // indirect; 后面还跟着其他说明
+func isIndirect(comment string) bool {
+	comment = strings.TrimSpace(comment)
+	return comment == "indirect" || strings.HasPrefix(comment, "indirect;")
+}
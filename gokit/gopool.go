@@ -1,39 +1,290 @@
 // 生产消费模型的协程池
 package gokit
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 type taskFun = func(int) error
 
+// 任务 panic 时的处理函数，可以用来记录日志或者告警
+type PoolPanicHandler func(workerID int, task *Task, err *PanicError)
+
+// 协程池的可选配置
+type PoolOption func(*Pool)
+
+// 设置任务 panic 时的处理函数，默认交给 SetPanicHandler 注册的全局处理函数
+func WithPoolPanicHandler(handler PoolPanicHandler) PoolOption {
+	return func(p *Pool) {
+		p.panicHandler = handler
+	}
+}
+
+// 设置任务队列的容量，默认为 0，即只有空闲的 worker 才能接收任务
+// 容量为 0 时，有空闲 worker 的 Submit 会直接交给它，只有所有 worker 都在忙时才按 OverflowPolicy 处理
+func WithQueueSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.queueSize = size
+	}
+}
+
+// 设置队列满时 Submit 的处理策略，默认为 OverflowBlock
+func WithOverflowPolicy(policy OverflowPolicy) PoolOption {
+	return func(p *Pool) {
+		p.overflowPolicy = policy
+	}
+}
+
+// 设置最多保留多少条失败任务的错误，超出后丢弃最早的，默认为 defaultMaxErrors
+// n <= 0 表示不收集错误，Errors 和 Err 始终为空，失败信息仍可以从 Future 或 OnTaskDone 钩子中拿到
+func WithMaxErrors(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxErrors = n
+	}
+}
+
+// 设置池子的父 context，父 context 取消后池子中所有任务拿到的 ctx 也会随之取消
+func WithPoolContext(ctx context.Context) PoolOption {
+	return func(p *Pool) {
+		p.ctx = ctx
+	}
+}
+
+// 任务优先级，worker 总是先取高优先级队列中的任务
+// 优先级是严格的，高优先级任务源源不断时低优先级任务会一直等待，批量回填这类任务适合放在 PriorityLow
+type TaskPriority int
+
+const (
+	PriorityLow    TaskPriority = -1
+	PriorityNormal TaskPriority = 0
+	PriorityHigh   TaskPriority = 1
+)
+
+// 任务队列满时的处理策略，只作用于 Submit，EntryChannel 始终是阻塞等待
+type OverflowPolicy int
+
+const (
+	// 阻塞等待，直到队列有空位
+	OverflowBlock OverflowPolicy = iota
+	// 直接拒绝，Submit 返回 ErrQueueFull
+	OverflowReject
+	// 丢弃队列中最早的任务，被丢弃任务的 Future 返回 ErrTaskDropped
+	// 队列容量为 0 时没有可丢弃的任务，退化为 OverflowBlock
+	OverflowDropOldest
+	// 由调用 Submit 的协程直接执行该任务，worker 编号为 -1
+	OverflowCallerRuns
+)
+
+// 默认最多保留的失败任务错误条数
+const defaultMaxErrors = 1000
+
+var (
+	// 协程池已停止，不再接收新任务
+	ErrPoolStopped = errors.New("gokit: pool stopped")
+	// 任务队列已满，OverflowReject 策略下返回
+	ErrQueueFull = errors.New("gokit: pool queue is full")
+	// 任务在执行前被 OverflowDropOldest 策略丢弃
+	ErrTaskDropped = errors.New("gokit: task dropped from full queue")
+
+	// 内部使用，表示 OverflowCallerRuns 策略下应由调用方执行任务
+	errCallerRuns = errors.New("gokit: caller runs")
+)
+
 // 定义池
 type Pool struct {
 	// 对外接收 task 的入口，使用管道保存 task
+	// 调用方可以 close 该管道，表示不再有新任务，池子会在处理完剩余任务后退出
 	EntryChannel chan *Task
 
 	// 协程池最大 worker 数量，即限定 Goroutine 的个数
 	workerNum int
+	// 每个 worker 各自的退出信号，缩容时从尾部关闭
+	workerQuits []chan struct{}
+	// 下一个新建 worker 的编号
+	nextWorkerID int
 
-	// 协程池内部的任务就绪队列
+	// 协程池内部的任务就绪队列，存放 PriorityNormal 的任务
 	TasksChannel chan *Task
+	// 高、低优先级任务的就绪队列
+	highChannel chan *Task
+	lowChannel  chan *Task
+	// 每个优先级队列各自的容量
+	queueSize int
+
+	overflowPolicy OverflowPolicy
+
+	// 读锁保护入队，写锁用于停止时关闭队列
+	mu      sync.RWMutex
+	stopped bool
+	// 保护 worker 的启动状态，不能与 mu 共用，否则 Run 会被阻塞在入队上的 Submit 卡住
+	stateMu  sync.Mutex
+	running  bool
+	closed   bool
+	stopOnce sync.Once
+	// 关闭后表示池子停止接收新任务
+	quit chan struct{}
+	// 关闭后表示所有 worker 都已退出
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// 已被 Submit 占用、正在等待交接任务的空闲 worker 数
+	claimed atomic.Int64
+	// 任务编号生成器
+	taskSeq atomic.Uint64
+	// 收集执行失败的任务，最多保留 maxErrors 条
+	errMu     sync.Mutex
+	errs      []*TaskError
+	maxErrors int
+
+	panicHandler PoolPanicHandler
+	onTaskStart  TaskStartHook
+	onTaskDone   TaskDoneHook
+	stats        poolStats
+
+	// 池子的生命周期，Stop 超时或者所有 worker 退出后取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // 定义 task，即要开协程去做的事情，每一个 task 都可以抽象成一个函数
 type Task struct {
-	fn taskFun
+	// 任务编号，进入池子时自动分配，从 1 开始递增
+	ID uint64
+	// 任务名称，可选，用于出错时定位是哪个任务
+	Name string
+	// 任务优先级，默认为 PriorityNormal
+	Priority TaskPriority
+	// 单个任务的超时时间，从开始执行时算起，0 表示不限制
+	// 只有通过 NewContextTask 创建、并且会检查 ctx 的任务才能被及时打断
+	Timeout time.Duration
+
+	fn     func(ctx context.Context, workerID int) (any, error)
+	future *Future
+}
+
+// 任务执行失败的信息
+type TaskError struct {
+	WorkerID int
+	TaskID   uint64
+	TaskName string
+	Err      error
+}
+
+func (e *TaskError) Error() string {
+	if e.TaskName != "" {
+		return fmt.Sprintf("gokit: task %d(%s) failed on worker %d: %v", e.TaskID, e.TaskName, e.WorkerID, e.Err)
+	}
+	return fmt.Sprintf("gokit: task %d failed on worker %d: %v", e.TaskID, e.WorkerID, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// 通过 Submit 提交的任务的执行结果
+type Future struct {
+	done   chan struct{}
+	result any
+	err    error
+}
+
+// 任务执行完毕后该管道会被关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// 阻塞等待任务执行完毕，返回任务的结果和错误
+// 任务出错时 err 为 *TaskError
+func (f *Future) Get() (any, error) {
+	<-f.done
+	return f.result, f.err
+}
+
+// 同 Get，ctx 到期时不再等待，返回 ctx.Err()
+func (f *Future) GetContext(ctx context.Context) (any, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *Future) resolve(result any, err error) {
+	f.result = result
+	f.err = err
+	close(f.done)
 }
 
 // 创建池子
 // cap 为池子容量，即最大开启多少个协程 worker
-func NewPool(cap int) *Pool {
+// opts 为可选配置，如：gokit.NewPool(10, gokit.WithPoolPanicHandler(handler))
+func NewPool(cap int, opts ...PoolOption) *Pool {
 	pool := Pool{
 		EntryChannel: make(chan *Task),
-		TasksChannel: make(chan *Task),
 		workerNum:    cap,
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+		panicHandler: defaultPoolPanicHandler,
+		maxErrors:    defaultMaxErrors,
+	}
+	for _, opt := range opts {
+		opt(&pool)
 	}
+	if pool.ctx == nil {
+		pool.ctx = context.Background()
+	}
+	pool.ctx, pool.cancel = context.WithCancel(pool.ctx)
+	pool.TasksChannel = make(chan *Task, pool.queueSize)
+	pool.highChannel = make(chan *Task, pool.queueSize)
+	pool.lowChannel = make(chan *Task, pool.queueSize)
 	return &pool
 }
 
+func defaultPoolPanicHandler(workerID int, task *Task, err *PanicError) {
+	handlePanic(err)
+}
+
 // 创建 task
 // fun 为具体要开协程去执行的函数
 func NewTask(fun taskFun) *Task {
+	task := Task{
+		fn: func(ctx context.Context, workerID int) (any, error) {
+			return nil, fun(workerID)
+		},
+	}
+	return &task
+}
+
+// 创建带返回值的 task，返回值可以通过 Submit 得到的 Future 取回
+func NewResultTask(fun func(int) (any, error)) *Task {
+	task := Task{
+		fn: func(ctx context.Context, workerID int) (any, error) {
+			return fun(workerID)
+		},
+	}
+	return &task
+}
+
+// 创建接收 ctx 的 task
+// ctx 派生自池子的生命周期，池子被取消或者超过 task.Timeout 时 ctx 会被取消，
+// 可以直接传给 HTTP、数据库等调用
+func NewContextTask(fun func(ctx context.Context, workerID int) error) *Task {
+	task := Task{
+		fn: func(ctx context.Context, workerID int) (any, error) {
+			return nil, fun(ctx, workerID)
+		},
+	}
+	return &task
+}
+
+// 创建接收 ctx 并且带返回值的 task
+func NewContextResultTask(fun func(ctx context.Context, workerID int) (any, error)) *Task {
 	task := Task{
 		fn: fun,
 	}
@@ -41,27 +292,458 @@ func NewTask(fun taskFun) *Task {
 }
 
 // 启动协程池进行工作
+// Run 会一直阻塞，直到 EntryChannel 被关闭或者调用了 Stop
 func (pool *Pool) Run() {
+	if pool.start() {
+		pool.forwardLoop()
+	}
+}
+
+// 启动协程池后立即返回，EntryChannel 的转发放到后台协程中进行
+// 适合只通过 Submit 提交任务的场景，不必再 go pool.Run()
+func (pool *Pool) Start() {
+	if pool.start() {
+		go pool.forwardLoop()
+	}
+}
+
+// 启动所有 worker，池子已经启动过或者已经停止时返回 false
+func (pool *Pool) start() bool {
+	pool.stateMu.Lock()
+	if pool.running || pool.closed {
+		pool.stateMu.Unlock()
+		return false
+	}
+	pool.running = true
 	// 首先根据协程池最打容量开启对应数量的 worker，每一个 worker 用一个 goroutine 承载
 	for i := 0; i < pool.workerNum; i++ {
-		go pool.worker(i)
+		pool.startWorker()
+	}
+	pool.stateMu.Unlock()
+
+	// 所有 worker 退出后通知 Wait
+	go func() {
+		pool.wg.Wait()
+		pool.cancel()
+		close(pool.done)
+	}()
+	return true
+}
+
+// 从 EntryChannel 取出外界传递过来的任务，然后将任务送进 TasksChannel 中
+func (pool *Pool) forwardLoop() {
+	for {
+		more, closed := pool.forward()
+		if closed {
+			// 调用方关闭了 EntryChannel，不再有新任务
+			pool.shutdown()
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// 停止协程池
+// 调用后不再接收新任务，已进入 TasksChannel 的任务会继续执行完毕，所有 worker 退出后返回 nil；
+// 如果 ctx 先到期则取消池子的 context 并返回 ctx.Err()，正在执行的任务会收到取消信号，
+// 队列中剩余的任务不再执行，直接以 context.Canceled 结束
+func (pool *Pool) Stop(ctx context.Context) error {
+	pool.shutdown()
+	select {
+	case <-pool.done:
+		return nil
+	case <-ctx.Done():
+		pool.cancel()
+		return ctx.Err()
+	}
+}
+
+// 池子的 context，Stop 超时或者池子结束后会被取消
+func (pool *Pool) Context() context.Context {
+	return pool.ctx
+}
+
+// 运行时调整 worker 数量，n 必须大于 0
+// 扩容立即生效；缩容时多出的 worker 会在执行完手头的任务后退出
+func (pool *Pool) Resize(n int) error {
+	if n < 1 {
+		return fmt.Errorf("gokit: invalid pool size %d", n)
+	}
+	pool.stateMu.Lock()
+	defer pool.stateMu.Unlock()
+	if pool.closed {
+		return ErrPoolStopped
+	}
+	if pool.running {
+		for len(pool.workerQuits) < n {
+			pool.startWorker()
+		}
+		for len(pool.workerQuits) > n {
+			last := len(pool.workerQuits) - 1
+			close(pool.workerQuits[last])
+			pool.workerQuits = pool.workerQuits[:last]
+		}
+	}
+	pool.workerNum = n
+	return nil
+}
+
+// 当前的 worker 数量
+func (pool *Pool) Size() int {
+	pool.stateMu.Lock()
+	defer pool.stateMu.Unlock()
+	return pool.workerNum
+}
+
+// 启动一个新的 worker，调用方需持有 stateMu
+func (pool *Pool) startWorker() {
+	quit := make(chan struct{})
+	pool.workerQuits = append(pool.workerQuits, quit)
+	pool.wg.Add(1)
+	go pool.worker(pool.nextWorkerID, quit)
+	pool.nextWorkerID++
+}
+
+// 阻塞等待所有 worker 退出，需配合 Stop 或关闭 EntryChannel 使用
+func (pool *Pool) Wait() {
+	<-pool.done
+}
+
+// 提交任务并返回 Future，可以通过 Future 等待任务的结果
+// 队列已满时按照 OverflowPolicy 处理，池子已停止则返回 ErrPoolStopped
+// 需要先调用 Run 或 Start 才会有 worker 消费任务
+func (pool *Pool) Submit(task *Task) (*Future, error) {
+	task.future = &Future{done: make(chan struct{})}
+	err := pool.push(task)
+	if err == nil || err == errCallerRuns {
+		pool.stats.submitted.Add(1)
+	}
+	if err == errCallerRuns {
+		pool.execute(-1, task)
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return task.future, nil
+}
+
+// 返回最近执行失败的任务，按失败的先后排列，最多 WithMaxErrors 设置的条数
+func (pool *Pool) Errors() []*TaskError {
+	pool.errMu.Lock()
+	defer pool.errMu.Unlock()
+	errs := make([]*TaskError, len(pool.errs))
+	copy(errs, pool.errs)
+	return errs
+}
+
+// 清空已收集的错误，长期运行的池子可以在上报后调用，避免重复上报
+func (pool *Pool) ClearErrors() {
+	pool.errMu.Lock()
+	pool.errs = nil
+	pool.errMu.Unlock()
+}
+
+// 将所有失败任务的错误合并为一个 error，没有失败的任务时返回 nil
+func (pool *Pool) Err() error {
+	var errs []error
+	for _, err := range pool.Errors() {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// 停止接收新任务并关闭任务队列，只会执行一次
+func (pool *Pool) shutdown() {
+	pool.stopOnce.Do(func() {
+		// 先关闭 quit 唤醒阻塞在入队上的调用方，再拿写锁等它们全部退出，之后才能安全关闭 TasksChannel
+		close(pool.quit)
+		pool.mu.Lock()
+		pool.stopped = true
+		close(pool.highChannel)
+		close(pool.TasksChannel)
+		close(pool.lowChannel)
+		pool.mu.Unlock()
+		pool.stateMu.Lock()
+		pool.closed = true
+		running := pool.running
+		pool.stateMu.Unlock()
+		// 没有启动过的池子没有 worker，直接标记结束
+		if !running {
+			pool.cancel()
+			close(pool.done)
+		}
+	})
+}
+
+// 将任务送入就绪队列
+func (pool *Pool) push(task *Task) error {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.stopped {
+		return ErrPoolStopped
+	}
+	task.ID = pool.taskSeq.Add(1)
+	queue := pool.queueOf(task)
+	// 队列有空位或者有 worker 正等在队列上时直接送入
+	select {
+	case queue <- task:
+		return nil
+	default:
+	}
+	// 有空闲的 worker 但它还没走到接收任务的地方，阻塞交给它，不算队列满
+	if handed, err := pool.handoff(queue, task); handed || err != nil {
+		return err
+	}
+	switch pool.overflowPolicy {
+	case OverflowReject:
+		pool.stats.rejected.Add(1)
+		return ErrQueueFull
+	case OverflowCallerRuns:
+		// 不能在持有读锁时执行任务，交给 Submit 在锁外执行
+		return errCallerRuns
+	case OverflowDropOldest:
+		if cap(queue) > 0 {
+			for {
+				select {
+				case queue <- task:
+					return nil
+				default:
+				}
+				select {
+				case old := <-queue:
+					pool.drop(old)
+				default:
+				}
+			}
+		}
+	}
+	select {
+	case queue <- task:
+		return nil
+	case <-pool.quit:
+		return ErrPoolStopped
 	}
+}
 
-	// 从 EntryChannel 取出外界传递过来的任务，然后将任务送进 TasksChannel 中
-	for task := range pool.EntryChannel {
-		pool.TasksChannel <- task
+// 占用一个空闲 worker 并阻塞地把任务交给它，没有空闲 worker 时 handed 为 false
+// 空闲 worker 数 = worker 总数 - 正在执行任务的 worker 数 - 已被其他 Submit 占用的数量，
+// 占用成功后 worker 很快就会回到接收任务的地方，不会长时间阻塞；池子还没启动时没有空闲 worker；调用方需持有读锁
+func (pool *Pool) handoff(queue chan *Task, task *Task) (handed bool, err error) {
+	pool.stateMu.Lock()
+	workers := int64(pool.workerNum)
+	if !pool.running {
+		workers = 0
+	}
+	pool.stateMu.Unlock()
+	for {
+		claimed := pool.claimed.Load()
+		if pool.stats.active.Load()+claimed >= workers {
+			return false, nil
+		}
+		if pool.claimed.CompareAndSwap(claimed, claimed+1) {
+			break
+		}
+	}
+	defer pool.claimed.Add(-1)
+	select {
+	case queue <- task:
+		return true, nil
+	case <-pool.quit:
+		return false, ErrPoolStopped
 	}
+}
 
-	// 执行完毕需要关闭 EntryChannel 和 TasksChannel
-	close(pool.TasksChannel)
-	close(pool.EntryChannel)
+// 任务所属优先级对应的就绪队列
+func (pool *Pool) queueOf(task *Task) chan *Task {
+	switch {
+	case task.Priority > PriorityNormal:
+		return pool.highChannel
+	case task.Priority < PriorityNormal:
+		return pool.lowChannel
+	default:
+		return pool.TasksChannel
+	}
+}
+
+// 丢弃一个还未执行的任务
+func (pool *Pool) drop(task *Task) {
+	pool.stats.dropped.Add(1)
+	err := pool.recordError(-1, task, ErrTaskDropped)
+	if task.future != nil {
+		task.future.resolve(nil, err)
+	}
+}
+
+// 从 EntryChannel 转发一个任务到 TasksChannel
+// more 为 false 表示应当结束转发，closed 表示 EntryChannel 已被调用方关闭
+// 全程持有读锁，保证取出的任务一定能在 TasksChannel 关闭前送进去，由 worker 在收尾时执行
+func (pool *Pool) forward() (more, closed bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.stopped {
+		return false, false
+	}
+	select {
+	case task, ok := <-pool.EntryChannel:
+		if !ok {
+			return false, true
+		}
+		task.ID = pool.taskSeq.Add(1)
+		pool.stats.submitted.Add(1)
+		pool.queueOf(task) <- task
+		return true, false
+	case <-pool.quit:
+		return false, false
+	}
 }
 
 // 从池子中拿出一个 worker 开始工作
-func (p *Pool) worker(work_ID int) {
-	// worker 不断的从 JobsChannel 内部任务队列中拿任务
-	for task := range p.TasksChannel {
+// quit 关闭表示该 worker 被缩容，执行完手头的任务后退出
+func (p *Pool) worker(work_ID int, quit chan struct{}) {
+	defer func() {
+		// 任务的 panic 已经在 execute 中捕获，走到这里说明是 panic 处理函数自身出了问题，
+		// 重新拉起一个同编号的 worker 顶上，保证池子的 worker 数量不变
+		if err := recover(); err != nil {
+			go p.worker(work_ID, quit)
+			return
+		}
+		p.wg.Done()
+	}()
+	// 按优先级从高到低排列，已关闭并取空的队列置为 nil
+	queues := []chan *Task{p.highChannel, p.TasksChannel, p.lowChannel}
+	// worker 不断的从 JobsChannel 内部任务队列中拿任务，所有队列关闭且取空后退出
+	for queues[0] != nil || queues[1] != nil || queues[2] != nil {
+		// 优先响应缩容，避免队列一直有任务时 worker 无法退出
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		task := pollQueues(queues)
+		if task == nil {
+			if queues[0] == nil && queues[1] == nil && queues[2] == nil {
+				return
+			}
+			// 所有队列都是空的，阻塞等待任意一个队列来任务
+			var ok bool
+			select {
+			case task, ok = <-queues[0]:
+				if !ok {
+					queues[0] = nil
+				}
+			case task, ok = <-queues[1]:
+				if !ok {
+					queues[1] = nil
+				}
+			case task, ok = <-queues[2]:
+				if !ok {
+					queues[2] = nil
+				}
+			case <-quit:
+				return
+			}
+			if !ok {
+				continue
+			}
+		}
 		// 如果拿到了任务则执行，即调用任务所绑定的业务函数
-		task.fn(work_ID)
+		p.execute(work_ID, task)
+	}
+}
+
+// 按优先级从高到低非阻塞地取一个任务，都没有任务时返回 nil
+// 已关闭并取空的队列会被置为 nil，nil 管道在 select 中永远不会就绪
+func pollQueues(queues []chan *Task) *Task {
+	for i, queue := range queues {
+		if queue == nil {
+			continue
+		}
+		select {
+		case task, ok := <-queue:
+			if ok {
+				return task
+			}
+			queues[i] = nil
+		default:
+		}
+	}
+	return nil
+}
+
+// 执行任务，记录错误并把结果交给 Future
+func (p *Pool) execute(workerID int, task *Task) {
+	// 调用方执行的任务不占用 worker
+	if workerID >= 0 {
+		p.stats.active.Add(1)
+		defer p.stats.active.Add(-1)
+	}
+	if p.onTaskStart != nil {
+		p.onTaskStart(workerID, task)
+	}
+	start := time.Now()
+	result, panicErr, err := p.call(workerID, task)
+	duration := time.Since(start)
+	p.stats.observe(duration)
+	p.stats.completed.Add(1)
+	if panicErr != nil {
+		p.stats.panics.Add(1)
+	}
+	if err != nil {
+		p.stats.failed.Add(1)
+		err = p.recordError(workerID, task, err)
+	}
+	if p.onTaskDone != nil {
+		p.onTaskDone(workerID, task, duration, err)
+	}
+	if task.future != nil {
+		task.future.resolve(result, err)
+	}
+	if panicErr != nil && p.panicHandler != nil {
+		p.panicHandler(workerID, task, panicErr)
+	}
+}
+
+// 记录任务错误，返回包装后的 *TaskError
+func (p *Pool) recordError(workerID int, task *Task, err error) error {
+	taskErr := &TaskError{
+		WorkerID: workerID,
+		TaskID:   task.ID,
+		TaskName: task.Name,
+		Err:      err,
+	}
+	p.errMu.Lock()
+	if p.maxErrors > 0 {
+		if len(p.errs) < p.maxErrors {
+			p.errs = append(p.errs, taskErr)
+		} else {
+			copy(p.errs, p.errs[1:])
+			p.errs[len(p.errs)-1] = taskErr
+		}
+	}
+	p.errMu.Unlock()
+	return taskErr
+}
+
+// 调用任务函数，任务 panic 时转换为 *PanicError 返回，避免一个任务拖垮整个进程
+func (p *Pool) call(workerID int, task *Task) (result any, panicErr *PanicError, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr = newPanicError(r)
+			result, err = nil, panicErr
+		}
+	}()
+	// 池子已经被取消的话，排队中的任务不再执行
+	if err = p.ctx.Err(); err != nil {
+		return
+	}
+	ctx := p.ctx
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
 	}
+	result, err = task.fn(ctx, workerID)
+	return
 }
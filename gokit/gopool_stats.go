@@ -0,0 +1,128 @@
+package gokit
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 计算耗时分位数时保留的最近任务数
+const latencySampleSize = 1024
+
+// 任务开始执行前调用
+type TaskStartHook func(workerID int, task *Task)
+
+// 任务执行完毕后调用，err 与 Future 拿到的错误一致，任务成功时为 nil
+type TaskDoneHook func(workerID int, task *Task, duration time.Duration, err error)
+
+// 设置任务开始执行时的钩子，可用于打点或者链路追踪
+func WithOnTaskStart(hook TaskStartHook) PoolOption {
+	return func(p *Pool) {
+		p.onTaskStart = hook
+	}
+}
+
+// 设置任务执行完毕时的钩子，可用于上报耗时和错误
+func WithOnTaskDone(hook TaskDoneHook) PoolOption {
+	return func(p *Pool) {
+		p.onTaskDone = hook
+	}
+}
+
+// 协程池的运行状态快照
+type PoolStats struct {
+	// worker 总数
+	Workers int
+	// 正在执行任务的 worker 数，等于 Workers 时说明池子已经饱和
+	ActiveWorkers int
+	// 各优先级队列中排队的任务总数
+	QueuedTasks int
+
+	// 成功进入池子的任务数
+	Submitted uint64
+	// 执行完毕的任务数，包含失败的任务
+	Completed uint64
+	// 执行失败的任务数，包含 panic 的任务
+	Failed uint64
+	// 发生 panic 的任务数
+	Panics uint64
+	// 队列满被拒绝的任务数
+	Rejected uint64
+	// 队列满被丢弃的任务数
+	Dropped uint64
+
+	// 最近 1024 个任务的平均耗时和分位数耗时
+	AvgLatency time.Duration
+	P50Latency time.Duration
+	P90Latency time.Duration
+	P99Latency time.Duration
+}
+
+// 协程池内部的计数器
+type poolStats struct {
+	active    atomic.Int64
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	failed    atomic.Uint64
+	panics    atomic.Uint64
+	rejected  atomic.Uint64
+	dropped   atomic.Uint64
+
+	// 最近任务耗时的环形缓冲区
+	latencyMu sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func (s *poolStats) observe(d time.Duration) {
+	s.latencyMu.Lock()
+	defer s.latencyMu.Unlock()
+	if len(s.latencies) < latencySampleSize {
+		s.latencies = append(s.latencies, d)
+		return
+	}
+	s.latencies[s.next] = d
+	s.next = (s.next + 1) % latencySampleSize
+}
+
+// 获取协程池当前的运行状态
+func (pool *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		Workers:       pool.Size(),
+		ActiveWorkers: int(pool.stats.active.Load()),
+		QueuedTasks:   len(pool.highChannel) + len(pool.TasksChannel) + len(pool.lowChannel),
+		Submitted:     pool.stats.submitted.Load(),
+		Completed:     pool.stats.completed.Load(),
+		Failed:        pool.stats.failed.Load(),
+		Panics:        pool.stats.panics.Load(),
+		Rejected:      pool.stats.rejected.Load(),
+		Dropped:       pool.stats.dropped.Load(),
+	}
+
+	pool.stats.latencyMu.Lock()
+	latencies := slices.Clone(pool.stats.latencies)
+	pool.stats.latencyMu.Unlock()
+	if len(latencies) == 0 {
+		return stats
+	}
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+	stats.AvgLatency = total / time.Duration(len(latencies))
+	stats.P50Latency = percentile(latencies, 50)
+	stats.P90Latency = percentile(latencies, 90)
+	stats.P99Latency = percentile(latencies, 99)
+	return stats
+}
+
+// 从已排序的耗时中取第 p 百分位
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
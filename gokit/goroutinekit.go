@@ -5,20 +5,112 @@ import (
 	"fmt"
 	"runtime"
 	"strconv"
+	"sync/atomic"
+
+	"github.com/textthree/cvgokit/syskit"
 )
 
+// 全局的 panic 处理函数，可用于记录日志或者告警
+type PanicHandler func(err *PanicError)
+
+var globalPanicHandler atomic.Pointer[PanicHandler]
+
+// 注册全局的 panic 处理函数，GoWithRecover 系列函数以及没有单独设置处理函数的协程池都会使用它
+// 传 nil 恢复默认处理，即打印 panic 信息和调用栈
+func SetPanicHandler(handler PanicHandler) {
+	if handler == nil {
+		globalPanicHandler.Store(nil)
+		return
+	}
+	globalPanicHandler.Store(&handler)
+}
+
+// 交给全局的 panic 处理函数
+func handlePanic(err *PanicError) {
+	if handler := globalPanicHandler.Load(); handler != nil {
+		(*handler)(err)
+		return
+	}
+	fmt.Println("[GoWithRecover panic recover]", err.Value)
+	fmt.Println(err.Stack)
+}
+
+// 协程中 recover 到的 panic，转换成 error 方便向上传递
+type PanicError struct {
+	// recover() 得到的原始值
+	Value any
+	// 发生 panic 时的调用栈
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// panic 的值本身是 error 时，可以通过 errors.Is / errors.As 继续判断
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// 需在 defer 的 recover 处调用，这样拿到的调用栈才包含 panic 发生的位置
+func newPanicError(value any) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: syskit.GetStack(),
+	}
+}
+
 // 封装协程异常捕获，只管一层
 // e.g: go GoWithRecover(fn)
 // 带参数方式：go GoWithRecover(func() { index("param") )
 func GoWithRecover(fn func()) {
 	defer func() {
 		if err := recover(); err != nil {
-			fmt.Println("[GoWithRecover panic recover]", err)
+			handlePanic(newPanicError(err))
 		}
 	}()
 	fn()
 }
 
+// 同步执行 fn，fn 的 panic 会转换成 *PanicError 返回，并交给全局的 panic 处理函数
+// e.g: err := CallWithRecover(func() error { return index("param") })
+func CallWithRecover(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := newPanicError(r)
+			handlePanic(panicErr)
+			err = panicErr
+		}
+	}()
+	return fn()
+}
+
+// 带错误返回的 GoWithRecover，fn 返回的错误或者 panic 转换成的 *PanicError 会交给 callbacks，没有错误时不调用
+// e.g: go GoWithRecoverErr(fn, func(err error) { log.Println(err) })
+func GoWithRecoverErr(fn func() error, callbacks ...func(error)) {
+	if err := CallWithRecover(fn); err != nil {
+		for _, callback := range callbacks {
+			callback(err)
+		}
+	}
+}
+
+// 开协程执行 fn，通过管道返回 fn 的错误或者 panic 转换成的 *PanicError，协程结束后管道关闭
+// e.g: err := <-GoWithRecoverChan(fn)
+func GoWithRecoverChan(fn func() error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		if err := CallWithRecover(fn); err != nil {
+			ch <- err
+		}
+	}()
+	return ch
+}
+
 // 获取协程ID，慎用，仅用作有时候要打印协程ID仅当调试debug看一下用。
 // 在C++中我们通过获取线程ID开辟不同空间保证线程安全。
 // 而golang中google官方自从1.4就取消了获取协程ID的接口,不建议照C++那样做，因为滥用协程ID会导致GC无法及时回收内存。
@@ -27,8 +119,26 @@ func GoWithRecover(fn func()) {
 func GetGoroutineID() uint64 {
 	b := make([]byte, 64)
 	runtime.Stack(b, false)
-	b = bytes.TrimPrefix(b, []byte("goroutine "))
-	b = b[:bytes.IndexByte(b, ' ')]
-	n, _ := strconv.ParseUint(string(b), 10, 64)
+	n, _ := parseGoroutineHeader(b)
 	return n
 }
+
+// 解析调用栈的第一行，如 "goroutine 18 [chan receive, 2 minutes]:"，返回协程ID和状态
+func parseGoroutineHeader(b []byte) (uint64, string) {
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	space := bytes.IndexByte(b, ' ')
+	if space < 0 {
+		return 0, ""
+	}
+	n, _ := strconv.ParseUint(string(b[:space]), 10, 64)
+	state := b[space+1:]
+	if end := bytes.IndexByte(state, ']'); end > 0 && state[0] == '[' {
+		state = state[1:end]
+		// 去掉等待时长
+		if comma := bytes.IndexByte(state, ','); comma >= 0 {
+			state = state[:comma]
+		}
+		return n, string(state)
+	}
+	return n, ""
+}
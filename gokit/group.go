@@ -0,0 +1,118 @@
+package gokit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 一组协程，用来代替手写的 sync.WaitGroup
+// 每个协程都带 panic 恢复，panic 会转换成 *PanicError 作为该协程的错误；
+// 通过 NewGroup 创建时，任意一个协程出错都会取消返回的 ctx，其余协程可以据此提前退出
+// 零值可以直接使用，此时没有 ctx 也不限制并发数
+// e.g:
+//
+//	g, ctx := gokit.NewGroup(ctx)
+//	g.SetLimit(10)
+//	for _, url := range urls {
+//		g.Go(func() error { return fetch(ctx, url) })
+//	}
+//	err := g.Wait()
+type Group struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// 限制并发数的信号量，nil 表示不限制
+	sem chan struct{}
+
+	errMu sync.Mutex
+	errs  []error
+}
+
+// 创建协程组，返回的 ctx 在第一个协程出错或者 Wait 返回时取消
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// 限制同时运行的协程数，n 小于 0 表示不限制
+// 必须在调用 Go 之前设置，有协程运行时修改会 panic
+func (g *Group) SetLimit(n int) {
+	if g.sem != nil && len(g.sem) != 0 {
+		panic(fmt.Errorf("gokit: modify group limit while %d goroutines are still active", len(g.sem)))
+	}
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// 开协程执行 fn，达到并发上限时阻塞直到有协程结束
+func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(fn)
+}
+
+// 达到并发上限时不阻塞，直接返回 false
+func (g *Group) TryGo(fn func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(fn)
+	return true
+}
+
+// 等待所有协程结束，返回第一个错误
+func (g *Group) Wait() error {
+	g.wait()
+	g.errMu.Lock()
+	defer g.errMu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	return g.errs[0]
+}
+
+// 等待所有协程结束，返回所有错误合并后的 error
+func (g *Group) WaitAll() error {
+	g.wait()
+	g.errMu.Lock()
+	defer g.errMu.Unlock()
+	return errors.Join(g.errs...)
+}
+
+func (g *Group) wait() {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+func (g *Group) start(fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := CallWithRecover(fn); err != nil {
+			g.errMu.Lock()
+			g.errs = append(g.errs, err)
+			g.errMu.Unlock()
+			if g.cancel != nil {
+				g.cancel()
+			}
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
@@ -0,0 +1,133 @@
+package gokit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// 按 key 限流，例如按用户 ID、IP 各自限流
+// 每个 key 的限流器在第一次使用时创建，空闲超过 ttl 或者 key 数量超过 maxKeys 时按最近最少使用淘汰，
+// 被淘汰的 key 再次出现时会重新创建一个满的限流器，所以 ttl 应不小于限流器从空到满所需的时间
+type KeyedLimiter struct {
+	mu         sync.Mutex
+	newLimiter func(key string) Limiter
+	ttl        time.Duration
+	maxKeys    int
+	entries    map[string]*list.Element
+	// 按最近使用时间排列，表头是最近使用的
+	lru *list.List
+}
+
+type keyedLimiterEntry struct {
+	key      string
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+// newLimiter 为每个 key 创建限流器
+// ttl 空闲多久后淘汰，0 表示不按时间淘汰；maxKeys 最多保留多少个 key，0 表示不限制
+func NewKeyedLimiter(newLimiter func(key string) Limiter, ttl time.Duration, maxKeys int) *KeyedLimiter {
+	return &KeyedLimiter{
+		newLimiter: newLimiter,
+		ttl:        ttl,
+		maxKeys:    maxKeys,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// 每个 key 一个 LazyTokenBucket，没有后台协程，适合 key 很多的场景
+// e.g: 每个 IP 每秒 10 次、突发 20 次，空闲 10 分钟淘汰，最多 10 万个 IP
+// gokit.NewKeyedTokenBucket(10, 20, 10*time.Minute, 100000)
+func NewKeyedTokenBucket(rate float64, burst int, ttl time.Duration, maxKeys int) *KeyedLimiter {
+	return NewKeyedLimiter(func(string) Limiter {
+		return NewLazyTokenBucket(rate, burst)
+	}, ttl, maxKeys)
+}
+
+// key 是否可以放行一个请求，不会阻塞
+func (this *KeyedLimiter) Allow(key string) bool {
+	return this.Get(key).TakeN(1)
+}
+
+// key 是否可以放行 n 个请求，不会阻塞
+func (this *KeyedLimiter) AllowN(key string, n int) bool {
+	return this.Get(key).TakeN(n)
+}
+
+// 阻塞等待 key 可以放行一个请求
+func (this *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return this.Get(key).Wait(ctx)
+}
+
+// 获取 key 对应的限流器，不存在时创建
+func (this *KeyedLimiter) Get(key string) Limiter {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.evict(now)
+	if elem, ok := this.entries[key]; ok {
+		entry := elem.Value.(*keyedLimiterEntry)
+		entry.lastUsed = now
+		this.lru.MoveToFront(elem)
+		return entry.limiter
+	}
+	entry := &keyedLimiterEntry{
+		key:      key,
+		limiter:  this.newLimiter(key),
+		lastUsed: now,
+	}
+	this.entries[key] = this.lru.PushFront(entry)
+	if this.maxKeys > 0 && this.lru.Len() > this.maxKeys {
+		this.remove(this.lru.Back())
+	}
+	return entry.limiter
+}
+
+// 移除 key 对应的限流器
+func (this *KeyedLimiter) Remove(key string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if elem, ok := this.entries[key]; ok {
+		this.remove(elem)
+	}
+}
+
+// 当前保留的 key 数量
+func (this *KeyedLimiter) Len() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.evict(time.Now())
+	return this.lru.Len()
+}
+
+// 关闭并移除所有 key 的限流器
+func (this *KeyedLimiter) Close() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for this.lru.Len() > 0 {
+		this.remove(this.lru.Back())
+	}
+}
+
+// 从表尾淘汰空闲超过 ttl 的 key，调用方需持有锁
+func (this *KeyedLimiter) evict(now time.Time) {
+	if this.ttl <= 0 {
+		return
+	}
+	for elem := this.lru.Back(); elem != nil; elem = this.lru.Back() {
+		if now.Sub(elem.Value.(*keyedLimiterEntry).lastUsed) < this.ttl {
+			return
+		}
+		this.remove(elem)
+	}
+}
+
+// 调用方需持有锁
+func (this *KeyedLimiter) remove(elem *list.Element) {
+	entry := this.lru.Remove(elem).(*keyedLimiterEntry)
+	delete(this.entries, entry.key)
+	entry.limiter.Close()
+}
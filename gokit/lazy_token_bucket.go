@@ -0,0 +1,143 @@
+package gokit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 惰性填充的令牌桶
+// 不需要后台协程，每次取令牌时根据距上次取令牌经过的时间一次性补充令牌，
+// 精度不受定时器限制，适合 10 万以上 qps 的热点路径，也支持每秒 0.5 个这样的小数速率
+type LazyTokenBucket struct {
+	mu sync.Mutex
+	// 每秒产生的令牌数
+	rate float64
+	// 桶容量，即允许的突发请求数
+	burst float64
+	// 当前令牌数，WaitN 预占令牌时可能为负数
+	tokens float64
+	// 上次补充令牌的时间
+	last time.Time
+}
+
+// rate 每秒产生的令牌数，例如 100000 即 10 万 qps，0.5 即每 2 秒一个令牌
+// burst 令牌桶容量，新建时桶是满的
+func NewLazyTokenBucket(rate float64, burst int) *LazyTokenBucket {
+	return &LazyTokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// 取令牌，await 的含义与 RateLimiter.TakeToken 相同
+func (this *LazyTokenBucket) TakeToken(await ...bool) bool {
+	if len(await) > 0 && await[0] {
+		return this.WaitN(context.Background(), 1) == nil
+	}
+	return this.TakeN(1)
+}
+
+// 一次取 n 个令牌，不会阻塞，令牌不足时一个都不取并返回 false
+func (this *LazyTokenBucket) TakeN(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.advance(time.Now())
+	if this.tokens < float64(n) {
+		return false
+	}
+	this.tokens -= float64(n)
+	return true
+}
+
+// 阻塞等待一个令牌，ctx 取消时返回 ctx.Err()
+func (this *LazyTokenBucket) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+// 阻塞等待 n 个令牌
+// 调用时先预占令牌再睡眠到令牌补足，多个等待者按调用顺序依次放行；
+// ctx 的截止时间早于令牌补足的时间时立即返回，中途取消会归还预占的令牌
+func (this *LazyTokenBucket) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if float64(n) > this.burst {
+		return fmt.Errorf("gokit: WaitN(%d) exceeds bucket burst %v", n, this.burst)
+	}
+	now := time.Now()
+	this.mu.Lock()
+	this.advance(now)
+	if this.tokens >= float64(n) {
+		this.tokens -= float64(n)
+		this.mu.Unlock()
+		return nil
+	}
+	if this.rate <= 0 {
+		this.mu.Unlock()
+		return fmt.Errorf("gokit: rate is %v, tokens will never refill", this.rate)
+	}
+	wait := this.durationOf(float64(n) - this.tokens)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(wait)) {
+		this.mu.Unlock()
+		return context.DeadlineExceeded
+	}
+	this.tokens -= float64(n)
+	this.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		this.mu.Lock()
+		this.advance(time.Now())
+		this.tokens = min(this.tokens+float64(n), this.burst)
+		this.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// 当前桶中的令牌数
+func (this *LazyTokenBucket) Tokens() float64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.advance(time.Now())
+	return this.tokens
+}
+
+// 当前的配额状态，retryAfter 为令牌不足一个时还需等待的时间
+func (this *LazyTokenBucket) Status() (limit, remaining int, retryAfter time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.advance(time.Now())
+	if this.tokens < 1 && this.rate > 0 {
+		retryAfter = this.durationOf(1 - this.tokens)
+	}
+	return int(this.burst), int(this.tokens), retryAfter
+}
+
+// 没有后台协程需要回收，保留该方法是为了与 RateLimiter 互换使用
+func (this *LazyTokenBucket) Close() {}
+
+// 按经过的时间补充令牌，调用方需持有锁
+func (this *LazyTokenBucket) advance(now time.Time) {
+	elapsed := now.Sub(this.last)
+	if elapsed <= 0 {
+		return
+	}
+	this.last = now
+	this.tokens = min(this.tokens+elapsed.Seconds()*this.rate, this.burst)
+}
+
+// 产生 tokens 个令牌所需的时间
+func (this *LazyTokenBucket) durationOf(tokens float64) time.Duration {
+	return time.Duration(tokens / this.rate * float64(time.Second))
+}
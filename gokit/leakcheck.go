@@ -0,0 +1,124 @@
+package gokit
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 一个协程的信息
+type GoroutineInfo struct {
+	ID uint64
+	// 协程状态，如 running、chan receive、select
+	State string
+	// 完整的调用栈，包含第一行 "goroutine N [state]:"
+	Stack string
+}
+
+// 检查泄漏时默认忽略的协程：测试框架自身的协程和运行时的常驻协程
+var defaultLeakIgnores = []string{
+	"testing.tRunner(",
+	"testing.(*T).Run(",
+	"testing.(*M).",
+	"testing.runTests(",
+	"os/signal.signal_recv(",
+	"runtime.ensureSigM(",
+}
+
+// 等待协程退出的最长时间，协程收到退出信号后往往还需要一点时间才能真正结束
+const leakCheckTimeout = time.Second
+
+// 获取当前所有协程的信息
+func Goroutines() []GoroutineInfo {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	var list []GoroutineInfo
+	for _, block := range bytes.Split(buf, []byte("\n\n")) {
+		block = bytes.TrimSpace(block)
+		if len(block) == 0 {
+			continue
+		}
+		id, state := parseGoroutineHeader(block)
+		list = append(list, GoroutineInfo{ID: id, State: state, Stack: string(block)})
+	}
+	return list
+}
+
+// CheckGoroutineLeak 用到的 testing.TB 方法，*testing.T、*testing.B 都满足
+// 这里不直接依赖 testing 包，避免使用 gokit 的业务程序也链接进 testing
+type LeakTB interface {
+	Helper()
+	Cleanup(func())
+	Errorf(format string, args ...any)
+}
+
+// 在测试开始时调用，测试结束时检查是否有测试期间新开的协程没有退出，有则让测试失败并打印这些协程的调用栈
+// ignore 为额外需要忽略的协程，调用栈中包含其中任意一个字符串即忽略，如某个库常驻的后台协程的函数名
+// e.g:
+//
+//	func TestXxx(t *testing.T) {
+//		gokit.CheckGoroutineLeak(t)
+//		...
+//	}
+func CheckGoroutineLeak(t LeakTB, ignore ...string) {
+	t.Helper()
+	before := make(map[uint64]bool)
+	for _, g := range Goroutines() {
+		before[g.ID] = true
+	}
+	t.Cleanup(func() {
+		t.Helper()
+		var leaks []GoroutineInfo
+		deadline := time.Now().Add(leakCheckTimeout)
+		for {
+			leaks = findLeaks(before, ignore)
+			if len(leaks) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if len(leaks) == 0 {
+			return
+		}
+		stacks := make([]string, len(leaks))
+		for i, g := range leaks {
+			stacks[i] = g.Stack
+		}
+		t.Errorf("gokit: found %d leaked goroutines:\n\n%s", len(leaks), strings.Join(stacks, "\n\n"))
+	})
+}
+
+// 找出 before 中没有、并且没有被忽略的协程
+func findLeaks(before map[uint64]bool, ignore []string) []GoroutineInfo {
+	current := GetGoroutineID()
+	var leaks []GoroutineInfo
+	for _, g := range Goroutines() {
+		if before[g.ID] || g.ID == current || leakIgnored(g.Stack, ignore) {
+			continue
+		}
+		leaks = append(leaks, g)
+	}
+	return leaks
+}
+
+func leakIgnored(stack string, ignore []string) bool {
+	for _, s := range defaultLeakIgnores {
+		if strings.Contains(stack, s) {
+			return true
+		}
+	}
+	for _, s := range ignore {
+		if strings.Contains(stack, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package gokit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 漏桶限流器
+// 请求按固定的时间间隔依次放行，最多允许 capacity 个请求排队，适合把突发的调用平滑成匀速调用外部服务
+type LeakyBucket struct {
+	mu sync.Mutex
+	// 相邻两个请求放行的间隔
+	interval time.Duration
+	// 最多排队的请求数
+	capacity int
+	// 下一个请求最早可以放行的时间
+	next time.Time
+}
+
+// rate 每秒放行的请求数，capacity 最多排队的请求数
+func NewLeakyBucket(rate float64, capacity int) *LeakyBucket {
+	return &LeakyBucket{
+		interval: time.Duration(float64(time.Second) / rate),
+		capacity: capacity,
+	}
+}
+
+func (this *LeakyBucket) TakeToken(await ...bool) bool {
+	return takeToken(this, await)
+}
+
+// 非阻塞地放行 n 个请求，只有不需要排队时才会成功
+func (this *LeakyBucket) TakeN(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	if n > this.capacity {
+		return false
+	}
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.next.After(now) {
+		return false
+	}
+	this.next = now.Add(time.Duration(n) * this.interval)
+	return true
+}
+
+func (this *LeakyBucket) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+// 排队等待放行 n 个请求，队列已满时等到有空位再排队
+func (this *LeakyBucket) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if n > this.capacity {
+		return errExceedLimit(n, this.capacity)
+	}
+	var start, end time.Time
+	err := waitUntil(ctx, func(now time.Time) (bool, time.Duration) {
+		var ok bool
+		var wait time.Duration
+		start, end, ok, wait = this.reserve(now, n)
+		return ok, wait
+	})
+	if err != nil {
+		return err
+	}
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		this.mu.Lock()
+		// 后面没有人排队的话把位置让出来
+		if this.next.Equal(end) {
+			this.next = start
+		}
+		this.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (this *LeakyBucket) Close() {}
+
+// 当前的配额状态，与 TakeN 的判断一致：没有请求在排队时 remaining 为 capacity，
+// 否则 remaining 为 0，retryAfter 为排在最后的请求放行前还需等待的时间
+func (this *LeakyBucket) Status() (limit, remaining int, retryAfter time.Duration) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.next.After(now) {
+		return this.capacity, 0, this.next.Sub(now)
+	}
+	return this.capacity, this.capacity, 0
+}
+
+// 在队尾预约 n 个请求的放行时间，队列已满时返回需要等待的时间
+func (this *LeakyBucket) reserve(now time.Time, n int) (start, end time.Time, ok bool, wait time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	start = this.next
+	if start.Before(now) {
+		start = now
+	}
+	// 队列中已经排队的请求数
+	queued := int(start.Sub(now) / this.interval)
+	if queued+n > this.capacity {
+		return start, start, false, time.Duration(queued+n-this.capacity) * this.interval
+	}
+	end = start.Add(time.Duration(n) * this.interval)
+	this.next = end
+	return start, end, true, 0
+}
@@ -0,0 +1,79 @@
+package gokit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 限流器的通用接口，不同算法的限流器可以互换使用
+//   - RateLimiter: 定时填充的令牌桶
+//   - LazyTokenBucket: 惰性填充的令牌桶，适合高 qps
+//   - SlidingWindowLog / SlidingWindowCounter: 滑动窗口，适合"每分钟最多 N 次"这类接口配额
+//   - LeakyBucket: 漏桶，以固定速率放行，适合平滑对外部服务的调用
+//   - GCRA: 通用信元速率算法，效果等同令牌桶，只需要保存一个时间戳
+//   - StoreLimiter: 基于共享存储的固定窗口计数，用于集群级别的配额
+type Limiter interface {
+	// 取一个令牌，await 为 true 时阻塞等待
+	TakeToken(await ...bool) bool
+	// 非阻塞地取 n 个令牌，不足时一个都不取
+	TakeN(n int) bool
+	// 阻塞等待一个令牌
+	Wait(ctx context.Context) error
+	// 阻塞等待 n 个令牌
+	WaitN(ctx context.Context, n int) error
+	// 释放限流器占用的资源
+	Close()
+}
+
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*LazyTokenBucket)(nil)
+	_ Limiter = (*SlidingWindowLog)(nil)
+	_ Limiter = (*SlidingWindowCounter)(nil)
+	_ Limiter = (*LeakyBucket)(nil)
+	_ Limiter = (*GCRA)(nil)
+	_ Limiter = (*StoreLimiter)(nil)
+)
+
+// TakeToken 的通用实现
+func takeToken(l Limiter, await []bool) bool {
+	if len(await) > 0 && await[0] {
+		return l.WaitN(context.Background(), 1) == nil
+	}
+	return l.TakeN(1)
+}
+
+// 反复尝试直到 try 成功或者 ctx 取消
+// try 失败时返回还需要等待多久才可能成功
+func waitUntil(ctx context.Context, try func(now time.Time) (bool, time.Duration)) error {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		ok, wait := try(time.Now())
+		if ok {
+			return nil
+		}
+		// 避免计算误差导致忙等
+		wait = max(wait, time.Millisecond)
+		if timer == nil {
+			timer = time.NewTimer(wait)
+		} else {
+			timer.Reset(wait)
+		}
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// n 超过限流器的上限时永远无法满足
+func errExceedLimit(n int, limit int) error {
+	return fmt.Errorf("gokit: WaitN(%d) exceeds limit %d", n, limit)
+}
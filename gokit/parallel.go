@@ -0,0 +1,77 @@
+package gokit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 用 concurrency 个协程并发处理 items，按 items 的顺序返回结果
+// 任意一个 fn 返回错误或者 panic 时取消 ctx，未开始的元素不再处理，返回第一个错误
+// e.g: users, err := gokit.ParallelMap(ctx, ids, 8, func(ctx context.Context, id int) (*User, error) { ... })
+func ParallelMap[T, R any](ctx context.Context, items []T, concurrency int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	if len(items) == 0 {
+		return results, ctx.Err()
+	}
+	concurrency = max(1, min(concurrency, len(items)))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	pool := NewPool(concurrency,
+		WithPoolContext(runCtx),
+		WithQueueSize(concurrency),
+		// panic 会作为错误返回，不需要再打印
+		WithPoolPanicHandler(nil),
+		WithOnTaskDone(func(workerID int, task *Task, duration time.Duration, err error) {
+			if err == nil {
+				return
+			}
+			once.Do(func() {
+				var taskErr *TaskError
+				if errors.As(err, &taskErr) {
+					err = taskErr.Err
+				}
+				firstErr = err
+				cancel()
+			})
+		}),
+	)
+	pool.Start()
+
+	for i, item := range items {
+		if runCtx.Err() != nil {
+			break
+		}
+		task := NewContextTask(func(ctx context.Context, workerID int) error {
+			result, err := fn(ctx, item)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			return nil
+		})
+		if _, err := pool.Submit(task); err != nil {
+			break
+		}
+	}
+	pool.Stop(context.Background())
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+	return results, ctx.Err()
+}
+
+// 用 concurrency 个协程并发处理 items，规则同 ParallelMap
+func ParallelForEach[T any](ctx context.Context, items []T, concurrency int, fn func(ctx context.Context, item T) error) error {
+	_, err := ParallelMap(ctx, items, concurrency, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, fn(ctx, item)
+	})
+	return err
+}
@@ -0,0 +1,180 @@
+package gokit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 可以报告当前配额状态的限流器，HTTP 中间件据此设置 X-RateLimit-* 响应头
+type LimiterStatus interface {
+	// limit 配额上限，remaining 剩余配额，retryAfter 距离下一个请求可以放行还要等多久
+	Status() (limit, remaining int, retryAfter time.Duration)
+}
+
+var (
+	_ LimiterStatus = (*RateLimiter)(nil)
+	_ LimiterStatus = (*LazyTokenBucket)(nil)
+	_ LimiterStatus = (*SlidingWindowLog)(nil)
+	_ LimiterStatus = (*SlidingWindowCounter)(nil)
+	_ LimiterStatus = (*LeakyBucket)(nil)
+	_ LimiterStatus = (*GCRA)(nil)
+	_ LimiterStatus = (*StoreLimiter)(nil)
+)
+
+// 从请求中提取限流的 key
+type RateLimitKeyFunc func(r *http.Request) string
+
+// 按客户端 IP 限流
+// trustProxy 为 true 时优先使用 X-Forwarded-For 的最后一个地址（即可信代理看到的对端地址）和 X-Real-IP，
+// 只应在服务部署在一层可信的反向代理之后时开启；X-Forwarded-For 前面的地址由客户端填写，不可信
+func KeyByIP(trustProxy bool) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return clientIP(r, trustProxy)
+	}
+}
+
+// 按请求头限流，例如按 X-API-Key；请求头为空时退回按 IP 限流
+// 两种 key 分别加上 hdr: 和 ip: 前缀，防止客户端在请求头中填写别人的 IP 耗尽对方的配额
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			return "hdr:" + value
+		}
+		return "ip:" + clientIP(r, false)
+	}
+}
+
+// 按请求路径限流
+func KeyByPath() RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.URL.Path
+	}
+}
+
+// 组合多个 key，例如同一个 IP 对同一个路径：gokit.KeyJoin(gokit.KeyByIP(false), gokit.KeyByPath())
+func KeyJoin(funcs ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, len(funcs))
+		for i, fn := range funcs {
+			parts[i] = fn(r)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			// 可信代理把对端地址追加在最后，可能有多个同名请求头
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if last = strings.TrimSpace(last); last != "" {
+				return last
+			}
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return realIP
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// net/http 限流中间件
+// e.g:
+//
+//	mw := gokit.NewRateLimitMiddleware(gokit.NewKeyedTokenBucket(10, 20, 10*time.Minute, 100000), gokit.KeyByIP(false))
+//	mw.Route("/api/login", gokit.NewKeyedTokenBucket(1, 5, 10*time.Minute, 100000))
+//	http.ListenAndServe(":8080", mw.Handler(mux))
+type RateLimitMiddleware struct {
+	limiter *KeyedLimiter
+	keyFunc RateLimitKeyFunc
+	// 按前缀长度从长到短排列
+	routes []rateLimitRoute
+
+	// 被限流时的响应，默认返回 429 Too Many Requests，响应头已经设置好
+	OnLimited http.HandlerFunc
+}
+
+type rateLimitRoute struct {
+	prefix  string
+	limiter *KeyedLimiter
+}
+
+// limiter 为默认的限流器，为 nil 时只有通过 Route 配置的路径会被限流
+func NewRateLimitMiddleware(limiter *KeyedLimiter, keyFunc RateLimitKeyFunc) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		limiter: limiter,
+		keyFunc: keyFunc,
+		OnLimited: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		},
+	}
+}
+
+// 为路径前缀单独设置限流器，有多个前缀匹配时使用最长的那个
+func (this *RateLimitMiddleware) Route(prefix string, limiter *KeyedLimiter) *RateLimitMiddleware {
+	this.routes = append(this.routes, rateLimitRoute{prefix: prefix, limiter: limiter})
+	sort.SliceStable(this.routes, func(i, j int) bool {
+		return len(this.routes[i].prefix) > len(this.routes[j].prefix)
+	})
+	return this
+}
+
+// 包装 handler，被限流的请求不会再交给 next
+func (this *RateLimitMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyed := this.match(r.URL.Path)
+		if keyed == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limiter := keyed.Get(this.keyFunc(r))
+		allowed := limiter.TakeN(1)
+
+		// 不支持 LimiterStatus 的限流器不知道要等多久，被限流时让客户端 1 秒后重试
+		retryAfter := time.Second
+		if status, ok := limiter.(LimiterStatus); ok {
+			limit, remaining, wait := status.Status()
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+			// 距离配额恢复的秒数，还有配额时为 0
+			w.Header().Set("X-RateLimit-Reset", ceilSeconds(wait))
+			if wait > 0 {
+				retryAfter = wait
+			}
+		}
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		seconds := ceilSeconds(retryAfter)
+		w.Header().Set("Retry-After", seconds)
+		w.Header().Set("X-RateLimit-Reset", seconds)
+		this.OnLimited(w, r)
+	})
+}
+
+// Retry-After 和 X-RateLimit-Reset 只支持整数秒，向上取整
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(max(d, 0).Seconds())))
+}
+
+func (this *RateLimitMiddleware) match(path string) *KeyedLimiter {
+	for _, route := range this.routes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.limiter
+		}
+	}
+	return this.limiter
+}
@@ -0,0 +1,255 @@
+package gokit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 基于 Redis 的限流计数存储
+// 直接使用 RESP 协议通信，不依赖第三方客户端，兼容 Redis 协议并支持 EVAL 的服务（如 KeyDB、Dragonfly）都可以使用；
+// 内部只维护一个连接，所有请求串行执行，出错后下次请求会自动重连
+// 没有 Redis 的环境可以连到进程内的 FakeRedisServer，e.g:
+//
+//	fake, _ := gokit.NewFakeRedisServer("")
+//	defer fake.Close()
+//	limiter := gokit.NewStoreLimiter(gokit.NewRedisStore(fake.Addr(), "", 0), "ratelimit:sms", 10, time.Minute)
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	// 建立连接的超时时间，默认 3 秒
+	DialTimeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Redis 返回的错误
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// addr 如：127.0.0.1:6379，password 为空时不认证，db 为 0 时不切换库
+func NewRedisStore(addr, password string, db int) *RedisStore {
+	return &RedisStore{
+		addr:        addr,
+		password:    password,
+		db:          db,
+		DialTimeout: 3 * time.Second,
+	}
+}
+
+// 检查并计数的 Lua 脚本，Redis 保证脚本执行期间不会穿插其他命令
+// KEYS[1] 计数的 key，ARGV[1] 本次的数量 n，ARGV[2] 配额 limit，ARGV[3] 窗口时长（毫秒）
+// 返回 {是否放行, 计数, 窗口剩余毫秒数}；key 没有过期时间说明是窗口内第一次计数，补上 PEXPIRE
+const redisTakeScript = `
+local n = tonumber(ARGV[1])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ok = 0
+if count + n <= tonumber(ARGV[2]) then
+	ok = 1
+	if n > 0 then
+		count = redis.call('INCRBY', KEYS[1], n)
+	end
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+	ttl = tonumber(ARGV[3])
+elseif ttl == -2 then
+	ttl = tonumber(ARGV[3])
+end
+return {ok, count, ttl}
+`
+
+// 通过 EVAL 执行 redisTakeScript，检查和计数在 Redis 端原子完成
+func (this *RedisStore) Take(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	replies, err := this.Do(ctx, []string{
+		"EVAL", redisTakeScript, "1", key,
+		strconv.FormatInt(n, 10), strconv.FormatInt(limit, 10), strconv.FormatInt(window.Milliseconds(), 10),
+	})
+	if err != nil {
+		return false, 0, 0, err
+	}
+	result, _ := replies[0].([]any)
+	if len(result) != 3 {
+		return false, 0, 0, fmt.Errorf("gokit: unexpected redis reply %v", replies[0])
+	}
+	ok, ok1 := result[0].(int64)
+	count, ok2 := result[1].(int64)
+	pttl, ok3 := result[2].(int64)
+	if !ok1 || !ok2 || !ok3 {
+		return false, 0, 0, fmt.Errorf("gokit: unexpected redis reply %v", replies[0])
+	}
+	return ok == 1, count, time.Duration(pttl) * time.Millisecond, nil
+}
+
+// 以管道方式执行多条命令，按顺序返回每条命令的结果
+// 结果类型：简单字符串为 string，整数为 int64，批量字符串为 []byte（不存在时为 nil），数组为 []any，
+// 任意一条命令返回错误时，err 为第一个 RedisError
+func (this *RedisStore) Do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if err := this.connect(ctx); err != nil {
+		return nil, err
+	}
+	replies, err := this.roundTrip(ctx, cmds)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 网络错误后连接的状态不可知，直接丢弃
+		this.closeConn()
+	}
+	return replies, err
+}
+
+// 关闭连接
+func (this *RedisStore) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.closeConn()
+}
+
+// 建立连接并完成认证和选库，调用方需持有锁
+func (this *RedisStore) connect(ctx context.Context) error {
+	if this.conn != nil {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: this.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", this.addr)
+	if err != nil {
+		return err
+	}
+	this.conn = conn
+	this.reader = bufio.NewReader(conn)
+	var setup [][]string
+	if this.password != "" {
+		setup = append(setup, []string{"AUTH", this.password})
+	}
+	if this.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(this.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := this.roundTrip(ctx, setup); err != nil {
+			this.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *RedisStore) closeConn() error {
+	if this.conn == nil {
+		return nil
+	}
+	err := this.conn.Close()
+	this.conn = nil
+	this.reader = nil
+	return err
+}
+
+// 发送命令并读取对应数量的回复，调用方需持有锁
+func (this *RedisStore) roundTrip(ctx context.Context, cmds [][]string) ([]any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	if err := this.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = appendRESPCommand(buf, cmd)
+	}
+	if _, err := this.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := readRESP(this.reader)
+		var redisErr RedisError
+		if err != nil && !errors.As(err, &redisErr) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// 按 RESP 协议编码一条命令
+func appendRESPCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// 按 RESP 协议读取一个回复
+func readRESP(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("gokit: invalid redis reply %q", line)
+	}
+	payload := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, RedisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = readRESP(reader); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("gokit: invalid redis reply %q", line)
+	}
+}
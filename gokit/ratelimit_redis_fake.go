@@ -0,0 +1,171 @@
+package gokit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 进程内的假 Redis 服务，只实现 RedisStore 用到的命令，计数交给 MemoryStore
+// 用于在没有 Redis 的环境中测试 RedisStore 和 StoreLimiter，支持 PING、AUTH、SELECT 和 RedisStore 自己的 EVAL 脚本，
+// 其他命令返回错误；多个连接共享同一份计数，不同的库互不影响
+type FakeRedisServer struct {
+	// 不为空时要求客户端先 AUTH
+	password string
+	listener net.Listener
+	store    *MemoryStore
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// 在 127.0.0.1 的随机端口上启动假服务，用完需调用 Close
+// password 不为空时客户端需要先 AUTH，与 NewRedisStore 的 password 对应
+func NewFakeRedisServer(password string) (*FakeRedisServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeRedisServer{
+		password: password,
+		listener: listener,
+		store:    NewMemoryStore(),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// 监听地址，可以直接传给 NewRedisStore
+func (this *FakeRedisServer) Addr() string {
+	return this.listener.Addr().String()
+}
+
+// 停止服务并断开所有连接，等待处理连接的协程退出
+func (this *FakeRedisServer) Close() error {
+	this.mu.Lock()
+	this.closed = true
+	for conn := range this.conns {
+		conn.Close()
+	}
+	this.mu.Unlock()
+	err := this.listener.Close()
+	this.wg.Wait()
+	return err
+}
+
+func (this *FakeRedisServer) serve() {
+	defer this.wg.Done()
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		this.mu.Lock()
+		if this.closed {
+			this.mu.Unlock()
+			conn.Close()
+			return
+		}
+		this.conns[conn] = struct{}{}
+		this.wg.Add(1)
+		this.mu.Unlock()
+		go this.handle(conn)
+	}
+}
+
+// 处理一个连接上的所有命令，每个连接有自己的认证状态和当前库
+func (this *FakeRedisServer) handle(conn net.Conn) {
+	defer this.wg.Done()
+	defer func() {
+		this.mu.Lock()
+		delete(this.conns, conn)
+		this.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	authed := this.password == ""
+	db := 0
+	for {
+		req, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+		items, _ := req.([]any)
+		args := make([]string, 0, len(items))
+		for _, item := range items {
+			arg, _ := item.([]byte)
+			args = append(args, string(arg))
+		}
+		if len(args) == 0 {
+			writeRESPError(writer, "ERR empty command")
+			writer.Flush()
+			continue
+		}
+		switch name := strings.ToUpper(args[0]); {
+		case name == "AUTH" && len(args) == 2:
+			if args[1] != this.password {
+				writeRESPError(writer, "WRONGPASS invalid password")
+				break
+			}
+			authed = true
+			writer.WriteString("+OK\r\n")
+		case !authed:
+			writeRESPError(writer, "NOAUTH Authentication required.")
+		case name == "PING":
+			writer.WriteString("+PONG\r\n")
+		case name == "SELECT" && len(args) == 2:
+			index, err := strconv.Atoi(args[1])
+			if err != nil {
+				writeRESPError(writer, "ERR invalid DB index")
+				break
+			}
+			db = index
+			writer.WriteString("+OK\r\n")
+		case name == "EVAL":
+			this.eval(writer, db, args[1:])
+		default:
+			writeRESPError(writer, "ERR unknown command '"+args[0]+"'")
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// 只认识 redisTakeScript，参数格式见该脚本的说明
+func (this *FakeRedisServer) eval(writer *bufio.Writer, db int, args []string) {
+	if len(args) != 6 || args[0] != redisTakeScript || args[1] != "1" {
+		writeRESPError(writer, "ERR fake redis only supports the RedisStore script")
+		return
+	}
+	var nums [3]int64
+	for i, arg := range args[3:] {
+		var err error
+		if nums[i], err = strconv.ParseInt(arg, 10, 64); err != nil {
+			writeRESPError(writer, "ERR value is not an integer or out of range")
+			return
+		}
+	}
+	key := strconv.Itoa(db) + ":" + args[2]
+	ok, count, ttl, _ := this.store.Take(context.Background(), key, nums[0], nums[1], time.Duration(nums[2])*time.Millisecond)
+	okInt := int64(0)
+	if ok {
+		okInt = 1
+	}
+	writer.WriteString("*3\r\n")
+	for _, n := range []int64{okInt, count, ttl.Milliseconds()} {
+		writer.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+	}
+}
+
+func writeRESPError(writer *bufio.Writer, msg string) {
+	writer.WriteString("-" + msg + "\r\n")
+}
@@ -0,0 +1,168 @@
+package gokit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 限流计数的共享存储，多个副本共用同一个存储即可实现集群级别的限流
+// 计数采用固定窗口：key 第一次出现时开启一个长度为 window 的窗口，窗口到期后计数清零
+type RateLimitStore interface {
+	// 检查并计数必须是一个原子操作：key 在当前窗口内的计数加上 n 不超过 limit 时才加 n 并返回 ok 为 true，
+	// 否则计数不变；count 为操作之后的计数，ttl 为窗口剩余时长；n 为 0 时只查询
+	Take(ctx context.Context, key string, n, limit int64, window time.Duration) (ok bool, count int64, ttl time.Duration, err error)
+}
+
+// 进程内的限流计数存储，单机使用或者测试时使用
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+	// 距上次清理过期 key 以来的调用次数
+	ops int
+}
+
+type memoryCounter struct {
+	count    int64
+	expireAt time.Time
+}
+
+// 每调用多少次 Take 清理一次过期的 key
+const memoryStoreSweepEvery = 1024
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+// 检查和计数在同一把锁内完成
+func (this *MemoryStore) Take(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, int64, time.Duration, error) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.ops++
+	if this.ops >= memoryStoreSweepEvery {
+		this.ops = 0
+		for k, counter := range this.counters {
+			if !now.Before(counter.expireAt) {
+				delete(this.counters, k)
+			}
+		}
+	}
+	counter, exists := this.counters[key]
+	if !exists || !now.Before(counter.expireAt) {
+		counter = &memoryCounter{expireAt: now.Add(window)}
+		this.counters[key] = counter
+	}
+	ok := counter.count+n <= limit
+	if ok {
+		counter.count += n
+	}
+	return ok, counter.count, counter.expireAt.Sub(now), nil
+}
+
+// 基于共享存储的限流器，window 时长内最多放行 limit 个请求
+// e.g: 整个集群每分钟最多调用 1000 次
+// gokit.NewStoreLimiter(gokit.NewRedisStore("127.0.0.1:6379", "", 0), "ratelimit:sms", 1000, time.Minute)
+type StoreLimiter struct {
+	store  RateLimitStore
+	key    string
+	limit  int64
+	window time.Duration
+
+	// 存储出错时是否放行，默认 true，避免存储故障时所有请求都被拒绝
+	FailOpen bool
+	// 每次访问存储的超时时间，默认 1 秒
+	Timeout time.Duration
+}
+
+func NewStoreLimiter(store RateLimitStore, key string, limit int, window time.Duration) *StoreLimiter {
+	return &StoreLimiter{
+		store:    store,
+		key:      key,
+		limit:    int64(limit),
+		window:   window,
+		FailOpen: true,
+		Timeout:  time.Second,
+	}
+}
+
+func (this *StoreLimiter) TakeToken(await ...bool) bool {
+	return takeToken(this, await)
+}
+
+// 存储出错时按 FailOpen 决定是否放行，需要拿到错误时使用 TakeNContext
+func (this *StoreLimiter) TakeN(n int) bool {
+	ok, _, err := this.TakeNContext(context.Background(), n)
+	if err != nil {
+		return this.FailOpen
+	}
+	return ok
+}
+
+// 尝试放行 n 个请求，不放行时 retryAfter 为窗口剩余时长
+func (this *StoreLimiter) TakeNContext(ctx context.Context, n int) (ok bool, retryAfter time.Duration, err error) {
+	if n <= 0 {
+		return true, 0, nil
+	}
+	if this.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, this.Timeout)
+		defer cancel()
+	}
+	// 超出配额时存储不会计数，不影响后面较小的请求
+	ok, _, ttl, err := this.store.Take(ctx, this.key, int64(n), this.limit, this.window)
+	if err != nil {
+		return false, 0, err
+	}
+	if ok {
+		return true, 0, nil
+	}
+	return false, ttl, nil
+}
+
+func (this *StoreLimiter) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+// 阻塞等待 n 个请求的配额，存储出错时按 FailOpen 决定放行还是返回错误
+func (this *StoreLimiter) WaitN(ctx context.Context, n int) error {
+	if int64(n) > this.limit {
+		return errExceedLimit(n, int(this.limit))
+	}
+	var storeErr error
+	err := waitUntil(ctx, func(now time.Time) (bool, time.Duration) {
+		ok, retryAfter, err := this.TakeNContext(ctx, n)
+		if err != nil {
+			storeErr = err
+			return true, 0
+		}
+		return ok, retryAfter
+	})
+	if storeErr != nil && !this.FailOpen {
+		return storeErr
+	}
+	return err
+}
+
+func (this *StoreLimiter) Close() {}
+
+// 当前的配额状态，需要访问一次存储，出错时按配额已满处理
+func (this *StoreLimiter) Status() (limit, remaining int, retryAfter time.Duration) {
+	ctx := context.Background()
+	if this.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, this.Timeout)
+		defer cancel()
+	}
+	_, count, ttl, err := this.store.Take(ctx, this.key, 0, this.limit, this.window)
+	if err != nil {
+		return int(this.limit), 0, 0
+	}
+	remaining = int(this.limit - count)
+	if remaining <= 0 {
+		retryAfter = ttl
+	}
+	return int(this.limit), remaining, retryAfter
+}
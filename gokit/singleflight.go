@@ -0,0 +1,140 @@
+package gokit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// fn 中调用了 runtime.Goexit，例如在测试中调用了 t.FailNow
+var errSingleFlightGoexit = errors.New("gokit: singleflight function called runtime.Goexit")
+
+// 合并对同一个 key 的并发调用，常用于防止缓存击穿：
+// 同一时刻只有一个协程真正执行 fn，其余协程等待并共享它的结果
+// 零值可以直接使用
+// e.g:
+//
+//	var sf gokit.SingleFlight
+//	v, err, _ := sf.Do("user:"+id, func() (any, error) { return loadUser(id) })
+type SingleFlight struct {
+	mu    sync.Mutex
+	calls map[string]*singleFlightCall
+
+	// 调用成功后结果继续共享多久，这段时间内对同一个 key 的调用直接返回该结果，0 表示调用结束即不再共享
+	// 出错和 panic 的结果不会共享
+	TTL time.Duration
+}
+
+// DoChan 的返回结果
+type SingleFlightResult struct {
+	Val any
+	Err error
+	// 是否与其他调用方共享了结果
+	Shared bool
+}
+
+type singleFlightCall struct {
+	done     chan struct{}
+	val      any
+	err      error
+	panicErr *PanicError
+	// 共享该结果的其他调用方数量，需持有 SingleFlight.mu 访问
+	dups int
+	// 调用结束时是否已有其他调用方共享该结果，供执行 fn 的调用方返回
+	shared bool
+	// 结果共享的截止时间，调用结束之前为零值
+	expireAt time.Time
+}
+
+// ttl 含义同 SingleFlight.TTL
+func NewSingleFlight(ttl time.Duration) *SingleFlight {
+	return &SingleFlight{TTL: ttl}
+}
+
+// 执行 fn 并返回结果，同一个 key 已有调用在进行或者结果仍在共享期内时直接等待并复用该结果
+// shared 表示结果是否与其他调用方共享；fn panic 时，所有等待该结果的 Do 调用方都会以 *PanicError 重新 panic
+func (g *SingleFlight) Do(key string, fn func() (any, error)) (v any, err error, shared bool) {
+	c, leader := g.acquire(key)
+	if leader {
+		g.doCall(c, key, fn)
+	} else {
+		<-c.done
+	}
+	if c.panicErr != nil {
+		panic(c.panicErr)
+	}
+	return c.val, c.err, !leader || c.shared
+}
+
+// 同 Do，但不阻塞，结果通过管道返回
+// fn panic 时不会在调用方协程重新 panic，而是以 *PanicError 作为 Err 返回
+func (g *SingleFlight) DoChan(key string, fn func() (any, error)) <-chan SingleFlightResult {
+	ch := make(chan SingleFlightResult, 1)
+	c, leader := g.acquire(key)
+	go func() {
+		if leader {
+			g.doCall(c, key, fn)
+		} else {
+			<-c.done
+		}
+		ch <- SingleFlightResult{Val: c.val, Err: c.err, Shared: !leader || c.shared}
+	}()
+	return ch
+}
+
+// 忘掉 key 正在进行的调用或者共享中的结果，之后对该 key 的调用会重新执行 fn
+func (g *SingleFlight) Forget(key string) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
+
+// 获取 key 对应的调用，leader 为 true 表示需要由当前调用方执行 fn
+func (g *SingleFlight) acquire(key string) (c *singleFlightCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = make(map[string]*singleFlightCall)
+	}
+	if c, ok := g.calls[key]; ok && (c.expireAt.IsZero() || time.Now().Before(c.expireAt)) {
+		c.dups++
+		return c, false
+	}
+	c = &singleFlightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+func (g *SingleFlight) doCall(c *singleFlightCall, key string, fn func() (any, error)) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			if r := recover(); r != nil {
+				c.panicErr = newPanicError(r)
+				c.err = c.panicErr
+			} else {
+				c.err = errSingleFlightGoexit
+			}
+		}
+		g.mu.Lock()
+		c.shared = c.dups > 0
+		if g.calls[key] == c {
+			if c.err == nil && g.TTL > 0 {
+				c.expireAt = time.Now().Add(g.TTL)
+				time.AfterFunc(g.TTL, func() {
+					g.mu.Lock()
+					if g.calls[key] == c {
+						delete(g.calls, key)
+					}
+					g.mu.Unlock()
+				})
+			} else {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	normalReturn = true
+}
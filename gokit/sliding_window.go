@@ -0,0 +1,200 @@
+package gokit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// 滑动窗口日志限流器
+// 记录窗口内每个请求的时间，任意 window 时长内最多放行 limit 个请求，精确但内存占用与 limit 成正比
+type SlidingWindowLog struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	// 窗口内请求的时间，按时间先后排列
+	log []time.Time
+}
+
+// limit 窗口内最多放行的请求数，window 窗口时长
+// e.g: 每分钟最多 60 次：gokit.NewSlidingWindowLog(60, time.Minute)
+func NewSlidingWindowLog(limit int, window time.Duration) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		limit:  limit,
+		window: window,
+		log:    make([]time.Time, 0, limit),
+	}
+}
+
+func (this *SlidingWindowLog) TakeToken(await ...bool) bool {
+	return takeToken(this, await)
+}
+
+func (this *SlidingWindowLog) TakeN(n int) bool {
+	ok, _ := this.take(time.Now(), n)
+	return ok
+}
+
+func (this *SlidingWindowLog) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+func (this *SlidingWindowLog) WaitN(ctx context.Context, n int) error {
+	if n > this.limit {
+		return errExceedLimit(n, this.limit)
+	}
+	return waitUntil(ctx, func(now time.Time) (bool, time.Duration) {
+		return this.take(now, n)
+	})
+}
+
+func (this *SlidingWindowLog) Close() {}
+
+// 当前的配额状态
+func (this *SlidingWindowLog) Status() (limit, remaining int, retryAfter time.Duration) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	boundary := now.Add(-this.window)
+	used := 0
+	for _, t := range this.log {
+		if t.After(boundary) {
+			used++
+		}
+	}
+	remaining = this.limit - used
+	if remaining <= 0 && len(this.log) > 0 {
+		// 窗口内的记录都在 log 尾部，等最早的一条滑出窗口
+		retryAfter = this.log[len(this.log)-used].Add(this.window).Sub(now)
+	}
+	return this.limit, remaining, retryAfter
+}
+
+// 尝试放行 n 个请求，失败时返回需要等待的时间
+func (this *SlidingWindowLog) take(now time.Time, n int) (bool, time.Duration) {
+	if n <= 0 {
+		return true, 0
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	// 清理已经滑出窗口的记录
+	boundary := now.Add(-this.window)
+	expired := 0
+	for expired < len(this.log) && !this.log[expired].After(boundary) {
+		expired++
+	}
+	this.log = append(this.log[:0], this.log[expired:]...)
+
+	if len(this.log)+n <= this.limit {
+		for i := 0; i < n; i++ {
+			this.log = append(this.log, now)
+		}
+		return true, 0
+	}
+	if n > this.limit {
+		return false, this.window
+	}
+	// 需要等最早的若干个请求滑出窗口
+	oldest := this.log[len(this.log)+n-this.limit-1]
+	return false, oldest.Add(this.window).Sub(now)
+}
+
+// 滑动窗口计数限流器
+// 只保存当前和上一个固定窗口的计数，按时间比例加权估算滑动窗口内的请求数，内存占用固定
+type SlidingWindowCounter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	// 当前固定窗口的起始时间
+	start    time.Time
+	current  int
+	previous int
+}
+
+// limit 窗口内最多放行的请求数，window 窗口时长
+func NewSlidingWindowCounter(limit int, window time.Duration) *SlidingWindowCounter {
+	return &SlidingWindowCounter{
+		limit:  limit,
+		window: window,
+		start:  time.Now(),
+	}
+}
+
+func (this *SlidingWindowCounter) TakeToken(await ...bool) bool {
+	return takeToken(this, await)
+}
+
+func (this *SlidingWindowCounter) TakeN(n int) bool {
+	ok, _ := this.take(time.Now(), n)
+	return ok
+}
+
+func (this *SlidingWindowCounter) Wait(ctx context.Context) error {
+	return this.WaitN(ctx, 1)
+}
+
+func (this *SlidingWindowCounter) WaitN(ctx context.Context, n int) error {
+	if n > this.limit {
+		return errExceedLimit(n, this.limit)
+	}
+	return waitUntil(ctx, func(now time.Time) (bool, time.Duration) {
+		return this.take(now, n)
+	})
+}
+
+func (this *SlidingWindowCounter) Close() {}
+
+// 尝试放行 n 个请求，失败时返回需要等待的时间
+func (this *SlidingWindowCounter) take(now time.Time, n int) (bool, time.Duration) {
+	if n <= 0 {
+		return true, 0
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	estimated := this.estimate(now)
+	if estimated+float64(n) <= float64(this.limit) {
+		this.current += n
+		return true, 0
+	}
+	return false, this.waitFor(now, n)
+}
+
+// 当前的配额状态，remaining 为按加权估算值还能放行的请求数，retryAfter 为配额用完时距离能再放行一个请求的时间
+func (this *SlidingWindowCounter) Status() (limit, remaining int, retryAfter time.Duration) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	remaining = int(math.Floor(float64(this.limit) - this.estimate(now)))
+	if remaining < 1 {
+		retryAfter = this.waitFor(now, 1)
+	}
+	return this.limit, remaining, retryAfter
+}
+
+// 滚动到 now 所在的固定窗口，返回估算的滑动窗口内的请求数，调用方需持有锁
+func (this *SlidingWindowCounter) estimate(now time.Time) float64 {
+	if elapsed := now.Sub(this.start); elapsed >= this.window {
+		windows := elapsed / this.window
+		if windows == 1 {
+			this.previous = this.current
+		} else {
+			this.previous = 0
+		}
+		this.current = 0
+		this.start = this.start.Add(windows * this.window)
+	}
+	weight := 1 - float64(now.Sub(this.start))/float64(this.window)
+	return float64(this.previous)*weight + float64(this.current)
+}
+
+// 距离能再放行 n 个请求还需要等待的时间，调用方需持有锁并已调用过 estimate
+func (this *SlidingWindowCounter) waitFor(now time.Time, n int) time.Duration {
+	elapsed := now.Sub(this.start)
+	// 当前窗口内上一个窗口的权重逐渐降低，算出估算值降到足够低的时间；当前窗口已满则等到下一个窗口
+	room := float64(this.limit - this.current - n)
+	if room >= 0 && this.previous > 0 {
+		return time.Duration((1-room/float64(this.previous))*float64(this.window)) - elapsed
+	}
+	return this.window - elapsed
+}
@@ -1,33 +1,57 @@
 package gokit
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type restrictor struct {
+// 限流器已关闭
+var ErrLimiterClosed = errors.New("gokit: rate limiter closed")
+
+// 基于令牌桶的限流器，由后台协程定时向桶中填充令牌
+// 不再使用时需要调用 Close 停止填充协程，否则每个限流器都会泄漏一个协程和一个定时器
+type RateLimiter struct {
 	tokenBucket  chan struct{}
 	fillInterval time.Duration
 	cap          int
+
+	// 一次取多个令牌时互斥，避免多个调用方各拿到一部分令牌互相等待
+	mu sync.Mutex
+	// 正在 WaitN 排队的调用方数量，大于 0 时 TakeN 直接失败，让排队者优先
+	waiters   atomic.Int32
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 // fillInterval 每隔多久向桶中填充一个令牌，例如：time.Microsecond * 10 每 10 毫秒填充一个令牌
 // cap 令牌桶容量，例如: 100。两个参数合起来，每 10 毫秒填充一个令牌，1 秒刚好填满 100 个，也就是 100 的 qps
-func NewTokenBucket(fillInterval time.Duration, cap int) restrictor {
-	s := restrictor{
+func NewRateLimiter(fillInterval time.Duration, cap int) *RateLimiter {
+	s := &RateLimiter{
 		fillInterval: fillInterval,
 		cap:          cap,
+		closed:       make(chan struct{}),
 	}
 	s.fillToken()
 	return s
 }
 
+// 同 NewRateLimiter，保留旧的函数名
+func NewTokenBucket(fillInterval time.Duration, cap int) *RateLimiter {
+	return NewRateLimiter(fillInterval, cap)
+}
+
 // 填充令牌
-func (this *restrictor) fillToken() {
+func (this *RateLimiter) fillToken() {
 	tokenBucket := make(chan struct{}, this.cap)
 	go func() {
 		// 这里可以看到 Go 的定时器存在大约 0.001 秒的误差
 		// 所以如果令牌桶大小在 1000 以上的填充可能会有一定的误差。对一般的服务来说，这一点误差无关紧要。
 		ticker := time.NewTicker(this.fillInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
@@ -38,6 +62,8 @@ func (this *restrictor) fillToken() {
 				default:
 				}
 				// fmt.Println("current token count: ", len(tokenBucket), time.Now())
+			case <-this.closed:
+				return
 			}
 		}
 	}()
@@ -48,7 +74,8 @@ func (this *restrictor) fillToken() {
 // await 是否等待令牌，默认 false
 // await 为 true 时如果桶中没有令牌则会阻塞线程，等待桶中有令牌的时候才返回 true，即把流量挡着等有空位一个放一个进来
 // await 为 false 时直接返回现在有没有令牌，例如没有令牌时调用者可以直接给用户返回"请稍后再试"的提示
-func (this *restrictor) TakeToken(await ...bool) bool {
+// 限流器关闭后不再阻塞，桶中没有令牌时直接返回 false
+func (this *RateLimiter) TakeToken(await ...bool) bool {
 	block := false
 	if len(await) > 0 {
 		block = await[0]
@@ -58,6 +85,8 @@ func (this *restrictor) TakeToken(await ...bool) bool {
 		select {
 		case <-this.tokenBucket:
 			takeResult = true
+		case <-this.closed:
+			takeResult = false
 		}
 	} else {
 		select {
@@ -71,3 +100,106 @@ func (this *restrictor) TakeToken(await ...bool) bool {
 	}
 	return takeResult
 }
+
+// 一次取 n 个令牌，用于按权重限流，例如按请求体大小计费
+// 不会阻塞，令牌不足 n 个时一个都不取并返回 false；有调用方正在 WaitN 排队时也返回 false，让排队者优先
+func (this *RateLimiter) TakeN(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	if n > this.cap || this.waiters.Load() > 0 {
+		return false
+	}
+	// 其他 TakeN 持有锁的时间很短，这里直接等待而不是失败
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for i := 0; i < n; i++ {
+		select {
+		case <-this.tokenBucket:
+		default:
+			this.putBack(i)
+			return false
+		}
+	}
+	return true
+}
+
+// 阻塞等待一个令牌，ctx 取消或者限流器关闭时返回对应的错误
+func (this *RateLimiter) Wait(ctx context.Context) error {
+	if this.isClosed() {
+		return ErrLimiterClosed
+	}
+	select {
+	case <-this.tokenBucket:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-this.closed:
+		return ErrLimiterClosed
+	}
+}
+
+// 阻塞等待 n 个令牌，中途 ctx 取消或者限流器关闭时已拿到的令牌会还回桶中
+func (this *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if n > this.cap {
+		return fmt.Errorf("gokit: WaitN(%d) exceeds bucket capacity %d", n, this.cap)
+	}
+	this.waiters.Add(1)
+	defer this.waiters.Add(-1)
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.isClosed() {
+		return ErrLimiterClosed
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-this.tokenBucket:
+		case <-ctx.Done():
+			this.putBack(i)
+			return ctx.Err()
+		case <-this.closed:
+			this.putBack(i)
+			return ErrLimiterClosed
+		}
+	}
+	return nil
+}
+
+// 关闭限流器，停止后台填充协程，可以重复调用
+func (this *RateLimiter) Close() {
+	this.closeOnce.Do(func() {
+		close(this.closed)
+	})
+}
+
+func (this *RateLimiter) isClosed() bool {
+	select {
+	case <-this.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// 归还 n 个令牌，桶满时多余的直接丢弃
+func (this *RateLimiter) putBack(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case this.tokenBucket <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// 当前的配额状态，retryAfter 为桶空时等待下一次填充的间隔
+func (this *RateLimiter) Status() (limit, remaining int, retryAfter time.Duration) {
+	remaining = len(this.tokenBucket)
+	if remaining == 0 {
+		retryAfter = this.fillInterval
+	}
+	return this.cap, remaining, retryAfter
+}